@@ -0,0 +1,69 @@
+package challenge
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CaptchaVerifier checks captcha responses against the siteverify API shared
+// by Cloudflare Turnstile, hCaptcha and reCAPTCHA.
+type CaptchaVerifier struct {
+	name      string
+	siteKey   string
+	secretKey string
+	verifyURL string
+}
+
+func NewCaptchaVerifier(name, siteKey, secretKey, verifyURL string) *CaptchaVerifier {
+	return &CaptchaVerifier{
+		name:      name,
+		siteKey:   siteKey,
+		secretKey: secretKey,
+		verifyURL: verifyURL,
+	}
+}
+
+func (v *CaptchaVerifier) Challenge(ctx context.Context) (*models.AnonymousChallenge, error) {
+	return &models.AnonymousChallenge{Type: v.name, SiteKey: v.siteKey}, nil
+}
+
+func (v *CaptchaVerifier) Verify(ctx context.Context, response, ip string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+
+	form := url.Values{
+		"secret":   {v.secretKey},
+		"response": {response},
+		"remoteip": {ip},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%s siteverify: %w", v.name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s siteverify: unexpected status %s", v.name, resp.Status)
+	}
+
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("%s siteverify: %w", v.name, err)
+	}
+
+	return result.Success, nil
+}
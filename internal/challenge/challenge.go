@@ -0,0 +1,57 @@
+package challenge
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/models"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Verifier tells humans from scripts before an anonymous link is created.
+type Verifier interface {
+	// Challenge describes what the client has to solve.
+	Challenge(ctx context.Context) (*models.AnonymousChallenge, error)
+	// Verify reports whether response solves a challenge. An error means the
+	// check itself could not be made.
+	Verify(ctx context.Context, response, ip string) (bool, error)
+}
+
+// httpClient is used for the captcha verification requests, so a hanging
+// captcha service can't hold a request forever.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+var captchaVerifyURLs = map[string]string{
+	"turnstile": "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	"hcaptcha":  "https://api.hcaptcha.com/siteverify",
+	"recaptcha": "https://www.google.com/recaptcha/api/siteverify",
+}
+
+// Open returns the Verifier selected by cfg.Driver.
+func Open(cfg config.ChallengeConfig) (Verifier, error) {
+	switch cfg.Driver {
+	case "none":
+		return NoopVerifier{}, nil
+	case "pow":
+		return NewProofOfWork(cfg.Difficulty, cfg.TTL), nil
+	case "turnstile", "hcaptcha", "recaptcha":
+		verifyURL := cfg.VerifyURL
+		if verifyURL == "" {
+			verifyURL = captchaVerifyURLs[cfg.Driver]
+		}
+		return NewCaptchaVerifier(cfg.Driver, cfg.SiteKey, cfg.SecretKey, verifyURL), nil
+	}
+	return nil, errors.New("unsupported challenge driver " + cfg.Driver)
+}
+
+// NoopVerifier lets every request through.
+type NoopVerifier struct{}
+
+func (NoopVerifier) Challenge(ctx context.Context) (*models.AnonymousChallenge, error) {
+	return &models.AnonymousChallenge{Type: "none"}, nil
+}
+
+func (NoopVerifier) Verify(ctx context.Context, response, ip string) (bool, error) {
+	return true, nil
+}
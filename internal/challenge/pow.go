@@ -0,0 +1,75 @@
+package challenge
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/bits"
+	"strings"
+	"time"
+)
+
+// ProofOfWork makes the client burn CPU time for every link, hashcash
+// style: the response is "<challenge>:<nonce>" and its SHA-256 hash has to
+// start with difficulty zero bits. Challenges are kept in Redis and can be
+// spent once.
+type ProofOfWork struct {
+	difficulty int
+	ttl        time.Duration
+}
+
+func NewProofOfWork(difficulty int, ttl time.Duration) *ProofOfWork {
+	return &ProofOfWork{difficulty: difficulty, ttl: ttl}
+}
+
+func powKey(challenge string) string {
+	return "challenge:pow:" + challenge
+}
+
+func (p *ProofOfWork) Challenge(ctx context.Context) (*models.AnonymousChallenge, error) {
+	challenge := rand.Text()
+	if err := config.Rdb.Set(ctx, powKey(challenge), 1, p.ttl).Err(); err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(p.ttl).UTC()
+	return &models.AnonymousChallenge{
+		Type:       "pow",
+		Challenge:  challenge,
+		Difficulty: p.difficulty,
+		ExpiresAt:  &expiresAt,
+	}, nil
+}
+
+func (p *ProofOfWork) Verify(ctx context.Context, response, ip string) (bool, error) {
+	challenge, nonce, ok := strings.Cut(response, ":")
+	if !ok || challenge == "" || nonce == "" || len(nonce) > 64 {
+		return false, nil
+	}
+
+	sum := sha256.Sum256([]byte(response))
+	if leadingZeroBits(sum[:]) < p.difficulty {
+		return false, nil
+	}
+
+	// The hash is checked first, so a wrong guess doesn't spend the
+	// challenge.
+	deleted, err := config.Rdb.Del(ctx, powKey(challenge)).Result()
+	if err != nil {
+		return false, err
+	}
+	return deleted == 1, nil
+}
+
+func leadingZeroBits(sum []byte) int {
+	n := 0
+	for _, b := range sum {
+		n += bits.LeadingZeros8(b)
+		if b != 0 {
+			break
+		}
+	}
+	return n
+}
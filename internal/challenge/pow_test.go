@@ -0,0 +1,117 @@
+package challenge
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"crypto/sha256"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestLeadingZeroBits(t *testing.T) {
+	tests := []struct {
+		sum  []byte
+		want int
+	}{
+		{[]byte{0x80, 0x00}, 0},
+		{[]byte{0x7f}, 1},
+		{[]byte{0x01, 0xff}, 7},
+		{[]byte{0x00, 0x80}, 8},
+		{[]byte{0x00, 0x0f, 0x00}, 12},
+		{[]byte{0x00, 0x00, 0x01}, 23},
+		{[]byte{0x00, 0x00}, 16},
+		{nil, 0},
+	}
+	for _, tc := range tests {
+		if got := leadingZeroBits(tc.sum); got != tc.want {
+			t.Errorf("leadingZeroBits(%x) = %d, want %d", tc.sum, got, tc.want)
+		}
+	}
+}
+
+// solve returns the first response for challenge whose hash does or, with
+// valid false, doesn't have difficulty leading zero bits.
+func solve(challenge string, difficulty int, valid bool) string {
+	for nonce := 0; ; nonce++ {
+		response := challenge + ":" + strconv.Itoa(nonce)
+		sum := sha256.Sum256([]byte(response))
+		if (leadingZeroBits(sum[:]) >= difficulty) == valid {
+			return response
+		}
+	}
+}
+
+func TestProofOfWorkVerify(t *testing.T) {
+	mr := miniredis.RunT(t)
+	previous := config.Rdb
+	config.Rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		config.Rdb.Close()
+		config.Rdb = previous
+	})
+
+	const difficulty = 8
+	p := NewProofOfWork(difficulty, time.Minute)
+	ctx := context.Background()
+
+	issued, err := p.Challenge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issued.Difficulty != difficulty || !mr.Exists(powKey(issued.Challenge)) {
+		t.Fatalf("Challenge() = %+v, not stored with difficulty %d", issued, difficulty)
+	}
+
+	verify := func(response string) bool {
+		t.Helper()
+		ok, err := p.Verify(ctx, response, "203.0.113.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if verify(solve(issued.Challenge, difficulty, false)) {
+		t.Error("a hash without enough leading zero bits was accepted")
+	}
+	if !mr.Exists(powKey(issued.Challenge)) {
+		t.Fatal("a wrong hash spent the challenge")
+	}
+
+	long := issued.Challenge + ":" + strings.Repeat("0", 65)
+	if verify(long) {
+		t.Error("a nonce longer than 64 characters was accepted")
+	}
+
+	for _, response := range []string{"", issued.Challenge, issued.Challenge + ":", ":123"} {
+		if verify(response) {
+			t.Errorf("malformed response %q was accepted", response)
+		}
+	}
+
+	response := solve(issued.Challenge, difficulty, true)
+	if !verify(response) {
+		t.Fatal("a valid response was refused")
+	}
+	if verify(response) {
+		t.Error("a challenge was spent twice")
+	}
+
+	if verify(solve("never-issued", difficulty, true)) {
+		t.Error("a response to a challenge that was never issued was accepted")
+	}
+
+	expiring, err := p.Challenge(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mr.FastForward(time.Minute)
+	if verify(solve(expiring.Challenge, difficulty, true)) {
+		t.Error("an expired challenge was accepted")
+	}
+}
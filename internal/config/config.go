@@ -0,0 +1,737 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Redis     RedisConfig     `yaml:"redis" toml:"redis"`
+	Auth      AuthConfig      `yaml:"auth" toml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rateLimit" toml:"rateLimit"`
+	Cache     CacheConfig     `yaml:"cache" toml:"cache"`
+	Clicks    ClickConfig     `yaml:"clicks" toml:"clicks"`
+	GeoIP     GeoIPConfig     `yaml:"geoip" toml:"geoip"`
+	Storage   StorageConfig   `yaml:"storage" toml:"storage"`
+	Mail      MailConfig      `yaml:"mail" toml:"mail"`
+	OAuth     OAuthConfig     `yaml:"oauth" toml:"oauth"`
+
+	AnonymousLinks AnonymousLinksConfig `yaml:"anonymousLinks" toml:"anonymousLinks"`
+	URLPolicy      URLPolicyConfig      `yaml:"urlPolicy" toml:"urlPolicy"`
+}
+
+type ServerConfig struct {
+	Port            int           `yaml:"port" toml:"port"`
+	AppURL          string        `yaml:"appUrl" toml:"appUrl"`
+	OriginURL       string        `yaml:"originUrl" toml:"originUrl"`
+	FrontendURL     string        `yaml:"frontendUrl" toml:"frontendUrl"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout" toml:"shutdownTimeout"`
+	// TrustedProxies lists the proxy IPs or CIDRs allowed to set
+	// X-Forwarded-For. None by default, so the client IP is the peer address.
+	TrustedProxies []string `yaml:"trustedProxies" toml:"trustedProxies"`
+}
+
+type DatabaseConfig struct {
+	URL string `yaml:"url" toml:"url"`
+}
+
+type RedisConfig struct {
+	URL string `yaml:"url" toml:"url"`
+}
+
+type AuthConfig struct {
+	AppSecret       string        `yaml:"appSecret" toml:"appSecret"`
+	RefreshSecret   string        `yaml:"refreshSecret" toml:"refreshSecret"`
+	AccessTokenTTL  time.Duration `yaml:"accessTokenTtl" toml:"accessTokenTtl"`
+	RefreshTokenTTL time.Duration `yaml:"refreshTokenTtl" toml:"refreshTokenTtl"`
+
+	RequireEmailVerification bool          `yaml:"requireEmailVerification" toml:"requireEmailVerification"`
+	EmailVerificationTTL     time.Duration `yaml:"emailVerificationTtl" toml:"emailVerificationTtl"`
+	PasswordResetTTL         time.Duration `yaml:"passwordResetTtl" toml:"passwordResetTtl"`
+
+	// TOTPEncryptionKey encrypts the two-factor secrets in the database. It
+	// defaults to AppSecret; set it to rotate AppSecret without turning off
+	// every enrolled authenticator.
+	TOTPEncryptionKey string `yaml:"totpEncryptionKey" toml:"totpEncryptionKey"`
+
+	LoginProtection LoginProtectionConfig `yaml:"loginProtection" toml:"loginProtection"`
+}
+
+// LoginProtectionConfig slows down password guessing. After FreeAttempts
+// failures of an account, or IPFreeAttempts failures from one IP, every
+// further failure doubles the wait before the next try, starting at
+// BaseDelay and capped at MaxDelay. LockoutThreshold failures lock the
+// account for LockoutDuration. Failures are forgotten after FailureWindow.
+type LoginProtectionConfig struct {
+	FreeAttempts     int           `yaml:"freeAttempts" toml:"freeAttempts"`
+	IPFreeAttempts   int           `yaml:"ipFreeAttempts" toml:"ipFreeAttempts"`
+	BaseDelay        time.Duration `yaml:"baseDelay" toml:"baseDelay"`
+	MaxDelay         time.Duration `yaml:"maxDelay" toml:"maxDelay"`
+	LockoutThreshold int           `yaml:"lockoutThreshold" toml:"lockoutThreshold"`
+	LockoutDuration  time.Duration `yaml:"lockoutDuration" toml:"lockoutDuration"`
+	FailureWindow    time.Duration `yaml:"failureWindow" toml:"failureWindow"`
+}
+
+// RateLimitConfig holds the token bucket of each route group. Requests and
+// Window are the default policy for routes without their own. Buckets are
+// kept per API key, per user or per IP, in that order of preference. IP is
+// checked per IP before authentication, so failed logins with bad tokens or
+// API keys are throttled too.
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests" toml:"requests"`
+	Window   time.Duration `yaml:"window" toml:"window"`
+	// FailOpen lets requests through when Redis is unavailable instead of
+	// answering 503.
+	FailOpen   bool            `yaml:"failOpen" toml:"failOpen"`
+	Redirect   RateLimitPolicy `yaml:"redirect" toml:"redirect"`
+	LinkCreate RateLimitPolicy `yaml:"linkCreate" toml:"linkCreate"`
+	Auth       RateLimitPolicy `yaml:"auth" toml:"auth"`
+	IP         RateLimitPolicy `yaml:"ip" toml:"ip"`
+}
+
+// RateLimitPolicy allows Requests per Window, refilled continuously, with
+// bursts of up to Requests.
+type RateLimitPolicy struct {
+	Requests int           `yaml:"requests" toml:"requests"`
+	Window   time.Duration `yaml:"window" toml:"window"`
+}
+
+// Default returns the policy of routes without their own.
+func (c RateLimitConfig) Default() RateLimitPolicy {
+	return RateLimitPolicy{Requests: c.Requests, Window: c.Window}
+}
+
+// AnonymousLinksConfig limits links created without an account. Every IP
+// may create Quota of them per QuotaWindow, and they stop redirecting after
+// TTL unless a registered user claims them first.
+type AnonymousLinksConfig struct {
+	Quota       int             `yaml:"quota" toml:"quota"`
+	QuotaWindow time.Duration   `yaml:"quotaWindow" toml:"quotaWindow"`
+	TTL         time.Duration   `yaml:"ttl" toml:"ttl"`
+	Challenge   ChallengeConfig `yaml:"challenge" toml:"challenge"`
+}
+
+type ChallengeConfig struct {
+	// Driver is "none", "pow" for a proof of work solved by the client, or
+	// "turnstile", "hcaptcha" or "recaptcha" to verify a captcha response.
+	Driver string `yaml:"driver" toml:"driver"`
+	// Difficulty is the number of leading zero bits a proof of work needs.
+	Difficulty int           `yaml:"difficulty" toml:"difficulty"`
+	TTL        time.Duration `yaml:"ttl" toml:"ttl"`
+	SiteKey    string        `yaml:"siteKey" toml:"siteKey"`
+	SecretKey  string        `yaml:"secretKey" toml:"secretKey"`
+	// VerifyURL overrides the siteverify endpoint of the captcha service.
+	VerifyURL string `yaml:"verifyUrl" toml:"verifyUrl"`
+}
+
+// URLPolicyConfig decides which destinations links may point to. Domains
+// match themselves and their subdomains. With AllowedDomains set, only those
+// domains are accepted.
+type URLPolicyConfig struct {
+	AllowedSchemes      []string `yaml:"allowedSchemes" toml:"allowedSchemes"`
+	BlockedDomains      []string `yaml:"blockedDomains" toml:"blockedDomains"`
+	AllowedDomains      []string `yaml:"allowedDomains" toml:"allowedDomains"`
+	BlockPrivateTargets bool     `yaml:"blockPrivateTargets" toml:"blockPrivateTargets"`
+	// HashListPath names a file of SHA-256 hashes of known bad hosts and
+	// URLs, one hex hash per line.
+	HashListPath string `yaml:"hashListPath" toml:"hashListPath"`
+}
+
+type CacheConfig struct {
+	LinkTTL      time.Duration `yaml:"linkTtl" toml:"linkTtl"`
+	ProfileTTL   time.Duration `yaml:"profileTtl" toml:"profileTtl"`
+	StatsTTL     time.Duration `yaml:"statsTtl" toml:"statsTtl"`
+	AnalyticsTTL time.Duration `yaml:"analyticsTtl" toml:"analyticsTtl"`
+	QRCodeTTL    time.Duration `yaml:"qrCodeTtl" toml:"qrCodeTtl"`
+}
+
+type ClickConfig struct {
+	QueueSize     int           `yaml:"queueSize" toml:"queueSize"`
+	BatchSize     int           `yaml:"batchSize" toml:"batchSize"`
+	FlushInterval time.Duration `yaml:"flushInterval" toml:"flushInterval"`
+}
+
+type GeoIPConfig struct {
+	DatabasePath string `yaml:"databasePath" toml:"databasePath"`
+}
+
+type StorageConfig struct {
+	// Driver is "local" or "s3".
+	Driver    string   `yaml:"driver" toml:"driver"`
+	LocalDir  string   `yaml:"localDir" toml:"localDir"`
+	PublicURL string   `yaml:"publicUrl" toml:"publicUrl"`
+	S3        S3Config `yaml:"s3" toml:"s3"`
+}
+
+type MailConfig struct {
+	// Driver is "log" or "smtp".
+	Driver string     `yaml:"driver" toml:"driver"`
+	From   string     `yaml:"from" toml:"from"`
+	Dir    string     `yaml:"dir" toml:"dir"`
+	SMTP   SMTPConfig `yaml:"smtp" toml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host" toml:"host"`
+	Port     int    `yaml:"port" toml:"port"`
+	Username string `yaml:"username" toml:"username"`
+	Password string `yaml:"password" toml:"password"`
+}
+
+type OAuthConfig struct {
+	// Providers are keyed by the name used in the login URL, for example
+	// /api/v1/auth/oauth/google.
+	Providers map[string]OAuthProviderConfig `yaml:"providers" toml:"providers"`
+	StateTTL  time.Duration                  `yaml:"stateTtl" toml:"stateTtl"`
+}
+
+type OAuthProviderConfig struct {
+	// Enabled has to be set for a provider of the config file to be used.
+	// Providers listed in OAUTH_PROVIDERS are enabled.
+	Enabled bool `yaml:"enabled" toml:"enabled"`
+	// Type is "oidc", configured through the Issuer discovery document, or
+	// "github", which has no OpenID Connect support. It defaults to github
+	// for the provider named github and to oidc otherwise.
+	Type         string   `yaml:"type" toml:"type"`
+	ClientID     string   `yaml:"clientId" toml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret" toml:"clientSecret"`
+	Issuer       string   `yaml:"issuer" toml:"issuer"`
+	AuthURL      string   `yaml:"authUrl" toml:"authUrl"`
+	TokenURL     string   `yaml:"tokenUrl" toml:"tokenUrl"`
+	APIURL       string   `yaml:"apiUrl" toml:"apiUrl"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"`
+}
+
+type S3Config struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint"`
+	Region    string `yaml:"region" toml:"region"`
+	Bucket    string `yaml:"bucket" toml:"bucket"`
+	AccessKey string `yaml:"accessKey" toml:"accessKey"`
+	SecretKey string `yaml:"secretKey" toml:"secretKey"`
+	PathStyle bool   `yaml:"pathStyle" toml:"pathStyle"`
+}
+
+func defaults() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:            8080,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Auth: AuthConfig{
+			AccessTokenTTL:       15 * time.Minute,
+			RefreshTokenTTL:      7 * 24 * time.Hour,
+			EmailVerificationTTL: 24 * time.Hour,
+			PasswordResetTTL:     time.Hour,
+			LoginProtection: LoginProtectionConfig{
+				FreeAttempts:     5,
+				IPFreeAttempts:   20,
+				BaseDelay:        time.Second,
+				MaxDelay:         5 * time.Minute,
+				LockoutThreshold: 10,
+				LockoutDuration:  15 * time.Minute,
+				FailureWindow:    time.Hour,
+			},
+		},
+		RateLimit: RateLimitConfig{
+			Requests: 60,
+			Window:   time.Minute,
+			FailOpen: true,
+			Redirect: RateLimitPolicy{
+				Requests: 300,
+				Window:   time.Minute,
+			},
+			LinkCreate: RateLimitPolicy{
+				Requests: 30,
+				Window:   time.Minute,
+			},
+			Auth: RateLimitPolicy{
+				Requests: 20,
+				Window:   time.Minute,
+			},
+			IP: RateLimitPolicy{
+				Requests: 600,
+				Window:   time.Minute,
+			},
+		},
+		Cache: CacheConfig{
+			LinkTTL:      15 * time.Minute,
+			ProfileTTL:   15 * time.Minute,
+			StatsTTL:     5 * time.Minute,
+			AnalyticsTTL: time.Minute,
+			QRCodeTTL:    24 * time.Hour,
+		},
+		Clicks: ClickConfig{
+			QueueSize:     10000,
+			BatchSize:     500,
+			FlushInterval: time.Second,
+		},
+		Storage: StorageConfig{
+			Driver:   "local",
+			LocalDir: "./uploads",
+			S3: S3Config{
+				Region:    "us-east-1",
+				PathStyle: true,
+			},
+		},
+		Mail: MailConfig{
+			Driver: "log",
+			From:   "Koda Shortlink <no-reply@localhost>",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+		OAuth: OAuthConfig{
+			StateTTL: 10 * time.Minute,
+		},
+		AnonymousLinks: AnonymousLinksConfig{
+			Quota:       10,
+			QuotaWindow: 24 * time.Hour,
+			TTL:         7 * 24 * time.Hour,
+			Challenge: ChallengeConfig{
+				Driver:     "none",
+				Difficulty: 20,
+				TTL:        5 * time.Minute,
+			},
+		},
+		URLPolicy: URLPolicyConfig{
+			AllowedSchemes:      []string{"http", "https"},
+			BlockPrivateTargets: true,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the optional YAML or TOML
+// file named by CONFIG_FILE and finally the environment, which wins over the
+// file. The result is validated before it is returned.
+func Load() (*Config, error) {
+	cfg := defaults()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, fmt.Errorf("config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, c)
+	case ".toml":
+		return toml.Unmarshal(data, c)
+	}
+	return errors.New("unsupported format, use .yaml, .yml or .toml")
+}
+
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	envString(&c.Server.AppURL, "APP_URL")
+	envString(&c.Server.OriginURL, "ORIGIN_URL")
+	envString(&c.Server.FrontendURL, "FRONTEND_URL")
+	envInt(&c.Server.Port, "PORT", &errs)
+	envDuration(&c.Server.ShutdownTimeout, "SHUTDOWN_TIMEOUT", &errs)
+	envList(&c.Server.TrustedProxies, "TRUSTED_PROXIES")
+
+	envString(&c.Database.URL, "DATABASE_URL")
+	envString(&c.Redis.URL, "REDIS_URL")
+
+	envString(&c.Auth.AppSecret, "APP_SECRET")
+	envString(&c.Auth.RefreshSecret, "REFRESH_SECRET")
+	envDuration(&c.Auth.AccessTokenTTL, "ACCESS_TOKEN_TTL", &errs)
+	envDuration(&c.Auth.RefreshTokenTTL, "REFRESH_TOKEN_TTL", &errs)
+	envBool(&c.Auth.RequireEmailVerification, "REQUIRE_EMAIL_VERIFICATION", &errs)
+	envDuration(&c.Auth.EmailVerificationTTL, "EMAIL_VERIFICATION_TTL", &errs)
+	envDuration(&c.Auth.PasswordResetTTL, "PASSWORD_RESET_TTL", &errs)
+	envString(&c.Auth.TOTPEncryptionKey, "TOTP_ENCRYPTION_KEY")
+	envInt(&c.Auth.LoginProtection.FreeAttempts, "LOGIN_FREE_ATTEMPTS", &errs)
+	envInt(&c.Auth.LoginProtection.IPFreeAttempts, "LOGIN_IP_FREE_ATTEMPTS", &errs)
+	envDuration(&c.Auth.LoginProtection.BaseDelay, "LOGIN_BACKOFF_BASE", &errs)
+	envDuration(&c.Auth.LoginProtection.MaxDelay, "LOGIN_BACKOFF_MAX", &errs)
+	envInt(&c.Auth.LoginProtection.LockoutThreshold, "LOGIN_LOCKOUT_THRESHOLD", &errs)
+	envDuration(&c.Auth.LoginProtection.LockoutDuration, "LOGIN_LOCKOUT_DURATION", &errs)
+	envDuration(&c.Auth.LoginProtection.FailureWindow, "LOGIN_FAILURE_WINDOW", &errs)
+
+	envInt(&c.RateLimit.Requests, "RATE_LIMIT_REQUESTS", &errs)
+	envDuration(&c.RateLimit.Window, "RATE_LIMIT_WINDOW", &errs)
+	envBool(&c.RateLimit.FailOpen, "RATE_LIMIT_FAIL_OPEN", &errs)
+	envInt(&c.RateLimit.Redirect.Requests, "RATE_LIMIT_REDIRECT_REQUESTS", &errs)
+	envDuration(&c.RateLimit.Redirect.Window, "RATE_LIMIT_REDIRECT_WINDOW", &errs)
+	envInt(&c.RateLimit.LinkCreate.Requests, "RATE_LIMIT_LINK_CREATE_REQUESTS", &errs)
+	envDuration(&c.RateLimit.LinkCreate.Window, "RATE_LIMIT_LINK_CREATE_WINDOW", &errs)
+	envInt(&c.RateLimit.Auth.Requests, "RATE_LIMIT_AUTH_REQUESTS", &errs)
+	envDuration(&c.RateLimit.Auth.Window, "RATE_LIMIT_AUTH_WINDOW", &errs)
+	envInt(&c.RateLimit.IP.Requests, "RATE_LIMIT_IP_REQUESTS", &errs)
+	envDuration(&c.RateLimit.IP.Window, "RATE_LIMIT_IP_WINDOW", &errs)
+
+	envDuration(&c.Cache.LinkTTL, "CACHE_LINK_TTL", &errs)
+	envDuration(&c.Cache.ProfileTTL, "CACHE_PROFILE_TTL", &errs)
+	envDuration(&c.Cache.StatsTTL, "CACHE_STATS_TTL", &errs)
+	envDuration(&c.Cache.AnalyticsTTL, "CACHE_ANALYTICS_TTL", &errs)
+	envDuration(&c.Cache.QRCodeTTL, "CACHE_QR_CODE_TTL", &errs)
+
+	envInt(&c.Clicks.QueueSize, "CLICK_QUEUE_SIZE", &errs)
+	envInt(&c.Clicks.BatchSize, "CLICK_BATCH_SIZE", &errs)
+	envDuration(&c.Clicks.FlushInterval, "CLICK_FLUSH_INTERVAL", &errs)
+
+	envString(&c.GeoIP.DatabasePath, "GEOIP_DB_PATH")
+
+	envString(&c.Storage.Driver, "STORAGE_DRIVER")
+	envString(&c.Storage.LocalDir, "STORAGE_LOCAL_DIR")
+	envString(&c.Storage.PublicURL, "STORAGE_PUBLIC_URL")
+	envString(&c.Storage.S3.Endpoint, "S3_ENDPOINT")
+	envString(&c.Storage.S3.Region, "S3_REGION")
+	envString(&c.Storage.S3.Bucket, "S3_BUCKET")
+	envString(&c.Storage.S3.AccessKey, "S3_ACCESS_KEY")
+	envString(&c.Storage.S3.SecretKey, "S3_SECRET_KEY")
+	envBool(&c.Storage.S3.PathStyle, "S3_PATH_STYLE", &errs)
+
+	envString(&c.Mail.Driver, "MAIL_DRIVER")
+	envString(&c.Mail.From, "MAIL_FROM")
+	envString(&c.Mail.Dir, "MAIL_DIR")
+	envString(&c.Mail.SMTP.Host, "SMTP_HOST")
+	envInt(&c.Mail.SMTP.Port, "SMTP_PORT", &errs)
+	envString(&c.Mail.SMTP.Username, "SMTP_USERNAME")
+	envString(&c.Mail.SMTP.Password, "SMTP_PASSWORD")
+
+	envInt(&c.AnonymousLinks.Quota, "ANON_LINK_QUOTA", &errs)
+	envDuration(&c.AnonymousLinks.QuotaWindow, "ANON_LINK_QUOTA_WINDOW", &errs)
+	envDuration(&c.AnonymousLinks.TTL, "ANON_LINK_TTL", &errs)
+	envString(&c.AnonymousLinks.Challenge.Driver, "ANON_CHALLENGE_DRIVER")
+	envInt(&c.AnonymousLinks.Challenge.Difficulty, "ANON_CHALLENGE_DIFFICULTY", &errs)
+	envDuration(&c.AnonymousLinks.Challenge.TTL, "ANON_CHALLENGE_TTL", &errs)
+	envString(&c.AnonymousLinks.Challenge.SiteKey, "CAPTCHA_SITE_KEY")
+	envString(&c.AnonymousLinks.Challenge.SecretKey, "CAPTCHA_SECRET_KEY")
+	envString(&c.AnonymousLinks.Challenge.VerifyURL, "CAPTCHA_VERIFY_URL")
+
+	envList(&c.URLPolicy.AllowedSchemes, "URL_ALLOWED_SCHEMES")
+	envList(&c.URLPolicy.BlockedDomains, "URL_BLOCKED_DOMAINS")
+	envList(&c.URLPolicy.AllowedDomains, "URL_ALLOWED_DOMAINS")
+	envBool(&c.URLPolicy.BlockPrivateTargets, "URL_BLOCK_PRIVATE_TARGETS", &errs)
+	envString(&c.URLPolicy.HashListPath, "URL_HASH_LIST_PATH")
+
+	envDuration(&c.OAuth.StateTTL, "OAUTH_STATE_TTL", &errs)
+	// OAUTH_PROVIDERS lists the providers configured through the
+	// environment, each one read from OAUTH_<NAME>_* variables.
+	if names := os.Getenv("OAUTH_PROVIDERS"); names != "" {
+		if c.OAuth.Providers == nil {
+			c.OAuth.Providers = map[string]OAuthProviderConfig{}
+		}
+		for name := range strings.SplitSeq(names, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+
+			provider := c.OAuth.Providers[name]
+			provider.Enabled = true
+			prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+			envString(&provider.Type, prefix+"TYPE")
+			envString(&provider.ClientID, prefix+"CLIENT_ID")
+			envString(&provider.ClientSecret, prefix+"CLIENT_SECRET")
+			envString(&provider.Issuer, prefix+"ISSUER")
+			envString(&provider.AuthURL, prefix+"AUTH_URL")
+			envString(&provider.TokenURL, prefix+"TOKEN_URL")
+			envString(&provider.APIURL, prefix+"API_URL")
+			envList(&provider.Scopes, prefix+"SCOPES")
+			c.OAuth.Providers[name] = provider
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Config) validate() error {
+	var errs []error
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"APP_URL", c.Server.AppURL},
+		{"DATABASE_URL", c.Database.URL},
+		{"REDIS_URL", c.Redis.URL},
+		{"APP_SECRET", c.Auth.AppSecret},
+		{"REFRESH_SECRET", c.Auth.RefreshSecret},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is not set", r.name))
+		}
+	}
+
+	if c.Auth.AppSecret != "" && c.Auth.AppSecret == c.Auth.RefreshSecret {
+		errs = append(errs, errors.New("APP_SECRET and REFRESH_SECRET must be different"))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Server.Port))
+	}
+
+	positiveDurations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SHUTDOWN_TIMEOUT", c.Server.ShutdownTimeout},
+		{"ACCESS_TOKEN_TTL", c.Auth.AccessTokenTTL},
+		{"REFRESH_TOKEN_TTL", c.Auth.RefreshTokenTTL},
+		{"EMAIL_VERIFICATION_TTL", c.Auth.EmailVerificationTTL},
+		{"PASSWORD_RESET_TTL", c.Auth.PasswordResetTTL},
+		{"LOGIN_BACKOFF_BASE", c.Auth.LoginProtection.BaseDelay},
+		{"LOGIN_BACKOFF_MAX", c.Auth.LoginProtection.MaxDelay},
+		{"LOGIN_LOCKOUT_DURATION", c.Auth.LoginProtection.LockoutDuration},
+		{"LOGIN_FAILURE_WINDOW", c.Auth.LoginProtection.FailureWindow},
+		{"RATE_LIMIT_WINDOW", c.RateLimit.Window},
+		{"RATE_LIMIT_REDIRECT_WINDOW", c.RateLimit.Redirect.Window},
+		{"RATE_LIMIT_LINK_CREATE_WINDOW", c.RateLimit.LinkCreate.Window},
+		{"RATE_LIMIT_AUTH_WINDOW", c.RateLimit.Auth.Window},
+		{"RATE_LIMIT_IP_WINDOW", c.RateLimit.IP.Window},
+		{"CACHE_LINK_TTL", c.Cache.LinkTTL},
+		{"CACHE_PROFILE_TTL", c.Cache.ProfileTTL},
+		{"CACHE_STATS_TTL", c.Cache.StatsTTL},
+		{"CACHE_ANALYTICS_TTL", c.Cache.AnalyticsTTL},
+		{"CACHE_QR_CODE_TTL", c.Cache.QRCodeTTL},
+		{"CLICK_FLUSH_INTERVAL", c.Clicks.FlushInterval},
+		{"OAUTH_STATE_TTL", c.OAuth.StateTTL},
+		{"ANON_LINK_QUOTA_WINDOW", c.AnonymousLinks.QuotaWindow},
+		{"ANON_LINK_TTL", c.AnonymousLinks.TTL},
+		{"ANON_CHALLENGE_TTL", c.AnonymousLinks.Challenge.TTL},
+	}
+	for _, d := range positiveDurations {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be a positive duration", d.name))
+		}
+	}
+
+	positiveInts := []struct {
+		name  string
+		value int
+	}{
+		{"RATE_LIMIT_REQUESTS", c.RateLimit.Requests},
+		{"RATE_LIMIT_REDIRECT_REQUESTS", c.RateLimit.Redirect.Requests},
+		{"RATE_LIMIT_LINK_CREATE_REQUESTS", c.RateLimit.LinkCreate.Requests},
+		{"RATE_LIMIT_AUTH_REQUESTS", c.RateLimit.Auth.Requests},
+		{"RATE_LIMIT_IP_REQUESTS", c.RateLimit.IP.Requests},
+		{"CLICK_QUEUE_SIZE", c.Clicks.QueueSize},
+		{"CLICK_BATCH_SIZE", c.Clicks.BatchSize},
+		{"LOGIN_FREE_ATTEMPTS", c.Auth.LoginProtection.FreeAttempts},
+		{"LOGIN_IP_FREE_ATTEMPTS", c.Auth.LoginProtection.IPFreeAttempts},
+		{"LOGIN_LOCKOUT_THRESHOLD", c.Auth.LoginProtection.LockoutThreshold},
+		{"ANON_LINK_QUOTA", c.AnonymousLinks.Quota},
+	}
+	for _, n := range positiveInts {
+		if n.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be greater than zero", n.name))
+		}
+	}
+
+	switch c.Storage.Driver {
+	case "local":
+		if c.Storage.LocalDir == "" {
+			errs = append(errs, errors.New("STORAGE_LOCAL_DIR is not set"))
+		}
+	case "s3":
+		s3Required := []struct {
+			name  string
+			value string
+		}{
+			{"S3_ENDPOINT", c.Storage.S3.Endpoint},
+			{"S3_REGION", c.Storage.S3.Region},
+			{"S3_BUCKET", c.Storage.S3.Bucket},
+			{"S3_ACCESS_KEY", c.Storage.S3.AccessKey},
+			{"S3_SECRET_KEY", c.Storage.S3.SecretKey},
+		}
+		for _, r := range s3Required {
+			if strings.TrimSpace(r.value) == "" {
+				errs = append(errs, fmt.Errorf("%s is required when STORAGE_DRIVER is s3", r.name))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("STORAGE_DRIVER must be local or s3, got %q", c.Storage.Driver))
+	}
+
+	switch c.Mail.Driver {
+	case "log":
+	case "smtp":
+		if c.Mail.SMTP.Host == "" {
+			errs = append(errs, errors.New("SMTP_HOST is required when MAIL_DRIVER is smtp"))
+		}
+		if c.Mail.SMTP.Port < 1 || c.Mail.SMTP.Port > 65535 {
+			errs = append(errs, fmt.Errorf("SMTP_PORT must be between 1 and 65535, got %d", c.Mail.SMTP.Port))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("MAIL_DRIVER must be log or smtp, got %q", c.Mail.Driver))
+	}
+
+	switch challenge := c.AnonymousLinks.Challenge; challenge.Driver {
+	case "none":
+	case "pow":
+		if challenge.Difficulty < 1 || challenge.Difficulty > 32 {
+			errs = append(errs, fmt.Errorf("ANON_CHALLENGE_DIFFICULTY must be between 1 and 32, got %d", challenge.Difficulty))
+		}
+	case "turnstile", "hcaptcha", "recaptcha":
+		if challenge.SiteKey == "" || challenge.SecretKey == "" {
+			errs = append(errs, fmt.Errorf("CAPTCHA_SITE_KEY and CAPTCHA_SECRET_KEY are required when ANON_CHALLENGE_DRIVER is %s", challenge.Driver))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("ANON_CHALLENGE_DRIVER must be none, pow, turnstile, hcaptcha or recaptcha, got %q", challenge.Driver))
+	}
+
+	if len(c.URLPolicy.AllowedSchemes) == 0 {
+		errs = append(errs, errors.New("URL_ALLOWED_SCHEMES must list at least one scheme"))
+	}
+
+	for name, provider := range c.OAuth.Providers {
+		if !provider.Enabled {
+			delete(c.OAuth.Providers, name)
+			continue
+		}
+		errs = append(errs, provider.applyDefaults(name)...)
+		c.OAuth.Providers[name] = provider
+	}
+
+	if c.Server.FrontendURL == "" {
+		c.Server.FrontendURL = c.Server.OriginURL
+	}
+	c.Server.FrontendURL = strings.TrimSuffix(c.Server.FrontendURL, "/")
+
+	if c.Auth.TOTPEncryptionKey == "" {
+		c.Auth.TOTPEncryptionKey = c.Auth.AppSecret
+	}
+
+	// Short URLs are built as AppURL + code.
+	if c.Server.AppURL != "" && !strings.HasSuffix(c.Server.AppURL, "/") {
+		c.Server.AppURL += "/"
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// applyDefaults fills the well known endpoints of google and github, so
+// only the client credentials have to be configured for them, and reports
+// what is missing.
+func (p *OAuthProviderConfig) applyDefaults(name string) []error {
+	var errs []error
+	prefix := "OAUTH_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+
+	if p.Type == "" {
+		p.Type = "oidc"
+		if name == "github" {
+			p.Type = "github"
+		}
+	}
+
+	switch p.Type {
+	case "oidc":
+		if p.Issuer == "" && name == "google" {
+			p.Issuer = "https://accounts.google.com"
+		}
+		if p.Issuer == "" {
+			errs = append(errs, fmt.Errorf("%sISSUER is required for OpenID Connect providers", prefix))
+		}
+	case "github":
+		if p.AuthURL == "" {
+			p.AuthURL = "https://github.com/login/oauth/authorize"
+		}
+		if p.TokenURL == "" {
+			p.TokenURL = "https://github.com/login/oauth/access_token"
+		}
+		if p.APIURL == "" {
+			p.APIURL = "https://api.github.com"
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%sTYPE must be oidc or github, got %q", prefix, p.Type))
+	}
+
+	if p.ClientID == "" {
+		errs = append(errs, fmt.Errorf("%sCLIENT_ID is not set", prefix))
+	}
+	if p.ClientSecret == "" {
+		errs = append(errs, fmt.Errorf("%sCLIENT_SECRET is not set", prefix))
+	}
+
+	return errs
+}
+
+func envString(target *string, name string) {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		*target = value
+	}
+}
+
+// envList reads a list separated by commas or spaces.
+func envList(target *[]string, name string) {
+	if value, ok := os.LookupEnv(name); ok && value != "" {
+		*target = strings.Fields(strings.ReplaceAll(value, ",", " "))
+	}
+}
+
+func envInt(target *int, name string, errs *[]error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be an integer, got %q", name, value))
+		return
+	}
+	*target = parsed
+}
+
+func envDuration(target *time.Duration, name string, errs *[]error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return
+	}
+
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be a duration like 15m or 168h, got %q", name, value))
+		return
+	}
+	*target = parsed
+}
+
+func envBool(target *bool, name string, errs *[]error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return
+	}
+
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be true or false, got %q", name, value))
+		return
+	}
+	*target = parsed
+}
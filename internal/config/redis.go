@@ -3,15 +3,14 @@ package config
 import (
 	"context"
 	"log"
-	"os"
 
 	"github.com/redis/go-redis/v9"
 )
 
 var Rdb *redis.Client
 
-func InitRedis() {
-	options, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+func InitRedis(redisURL string) {
+	options, err := redis.ParseURL(redisURL)
 	if err != nil {
 		log.Fatalf("Invalid redis URL: %v", err)
 	}
@@ -24,3 +23,13 @@ func InitRedis() {
 		log.Println("Connected to Redis successfully!")
 	}
 }
+
+func CloseRedis() {
+	if Rdb != nil {
+		if err := Rdb.Close(); err != nil {
+			log.Printf("Failed to close Redis connection: %v", err)
+			return
+		}
+		log.Println("Redis connection closed")
+	}
+}
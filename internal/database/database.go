@@ -3,7 +3,6 @@ package database
 import (
 	"context"
 	"log"
-	"os"
 	"strings"
 	"sync"
 
@@ -13,16 +12,11 @@ import (
 var DB *pgxpool.Pool
 var once sync.Once
 
-func InitDatabase() {
+func InitDatabase(databaseURL string) {
 	once.Do(func() {
 		ctx := context.Background()
 
-		connStr := os.Getenv("DATABASE_URL")
-		if connStr == "" {
-			log.Fatal("DATABASE_URL is not set")
-		}
-
-		connStr = strings.Replace(connStr, "postgresql://", "postgres://", 1)
+		connStr := strings.Replace(databaseURL, "postgresql://", "postgres://", 1)
 
 		var err error
 		DB, err = pgxpool.New(ctx, connStr)
@@ -0,0 +1,88 @@
+package geoip
+
+import (
+	"log"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+type Location struct {
+	Country string
+	City    string
+}
+
+// Locator resolves the location of a client IP. Lookups never fail loudly,
+// an unknown address simply yields an empty Location.
+type Locator interface {
+	Lookup(ip net.IP) Location
+	Close() error
+}
+
+// Open returns a Locator backed by the MaxMind format database at path, or a
+// no-op Locator when path is empty.
+func Open(path string) (Locator, error) {
+	if path == "" {
+		log.Println("GeoIP database not configured, click locations will be empty")
+		return NoopLocator{}, nil
+	}
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("GeoIP database loaded: %s", reader.Metadata.DatabaseType)
+	return &MMDBLocator{reader: reader}, nil
+}
+
+type NoopLocator struct{}
+
+func (NoopLocator) Lookup(ip net.IP) Location {
+	return Location{}
+}
+
+func (NoopLocator) Close() error {
+	return nil
+}
+
+// MMDBLocator reads GeoLite2/GeoIP2 City or Country databases, or any other
+// .mmdb file using the same record layout.
+type MMDBLocator struct {
+	reader *maxminddb.Reader
+}
+
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+}
+
+func (l *MMDBLocator) Lookup(ip net.IP) Location {
+	if ip == nil {
+		return Location{}
+	}
+
+	var record mmdbRecord
+	if err := l.reader.Lookup(ip, &record); err != nil {
+		return Location{}
+	}
+
+	country := record.Country.Names["en"]
+	if country == "" {
+		country = record.Country.ISOCode
+	}
+
+	return Location{
+		Country: country,
+		City:    record.City.Names["en"],
+	}
+}
+
+func (l *MMDBLocator) Close() error {
+	return l.reader.Close()
+}
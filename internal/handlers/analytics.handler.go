@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/pkg/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AnalyticsHandler struct {
+	analyticsService *services.AnalyticsService
+}
+
+func NewAnalyticsHandler(analyticsService *services.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		analyticsService: analyticsService,
+	}
+}
+
+// LinkAnalytics godoc
+// @Summary      Get short link analytics
+// @Description  Daily clicks and top referrer domains, browsers, operating systems, device types and countries of one short link
+// @Tags         links
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        shortCode  path   string  true   "Short code"
+// @Param        from       query  string  false  "Start date (YYYY-MM-DD), defaults to 6 days before to"
+// @Param        to         query  string  false  "End date inclusive (YYYY-MM-DD), defaults to today"
+// @Param        limit      query  int     false  "Number of entries per breakdown" default(5)
+// @Success      200  {object}  response.ResponseSuccess{data=repository.LinkAnalytics}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      403  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /links/{shortCode}/analytics [get]
+func (h *AnalyticsHandler) LinkAnalytics(c *gin.Context) {
+	userId := c.GetInt("userId")
+	shortCode := c.Param("shortCode")
+
+	to := time.Now().UTC().Truncate(24 * time.Hour)
+	if t := c.Query("to"); t != "" {
+		parsed, err := time.Parse("2006-01-02", t)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Invalid to date, use YYYY-MM-DD",
+			})
+			return
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -6)
+	if f := c.Query("from"); f != "" {
+		parsed, err := time.Parse("2006-01-02", f)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Invalid from date, use YYYY-MM-DD",
+			})
+			return
+		}
+		from = parsed
+	}
+
+	limit := 5
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 50 {
+			limit = parsed
+		}
+	}
+
+	data, err := h.analyticsService.LinkAnalytics(c.Request.Context(), userId, shortCode, from, to, limit)
+	if err != nil {
+		switch err.Error() {
+		case "invalid date range", "date range too large":
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   err.Error(),
+			})
+		case "short link not found":
+			c.JSON(http.StatusNotFound, response.ResponseError{
+				Success: false,
+				Error:   "Short link not found",
+			})
+		case "unauthorized access":
+			c.JSON(http.StatusForbidden, response.ResponseError{
+				Success: false,
+				Error:   "Access denied",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ResponseError{
+				Success: false,
+				Error:   "Failed to fetch analytics",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Success get link analytics",
+		Data:    data,
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/pkg/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ApiKeyHandler struct {
+	apiKeyService *services.ApiKeyService
+}
+
+func NewApiKeyHandler(apiKeyService *services.ApiKeyService) *ApiKeyHandler {
+	return &ApiKeyHandler{
+		apiKeyService: apiKeyService,
+	}
+}
+
+// ListApiKeys godoc
+// @Summary      List API keys
+// @Description  List the active API keys of the logged in user. Only the prefix of each key is shown
+// @Tags         api-keys
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  response.ResponseSuccess{data=[]models.ApiKey}
+// @Failure      401  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /api-keys [get]
+func (h *ApiKeyHandler) ListApiKeys(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	keys, err := h.apiKeyService.ListApiKeys(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to get API keys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Success get API keys",
+		Data:    keys,
+	})
+}
+
+// CreateApiKey godoc
+// @Summary      Create API key
+// @Description  Create an API key with the given scopes and optional expiry. The key is only returned in this response, store it safely. Send it as "Authorization: ApiKey <key>" or in the X-API-Key header
+// @Tags         api-keys
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.CreateApiKeyRequest  true  "API key name, scopes and expiry"
+// @Success      201  {object}  response.ResponseSuccess{data=models.CreateApiKeyResponse}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /api-keys [post]
+func (h *ApiKeyHandler) CreateApiKey(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.CreateApiKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide a name and at least one valid scope (links:read, links:write, analytics:read)",
+		})
+		return
+	}
+
+	key, err := h.apiKeyService.CreateApiKey(c.Request.Context(), userId, &req)
+	if err != nil {
+		switch err.Error() {
+		case "expiry must be in the future":
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Expiry must be in the future",
+			})
+		case "api key limit reached":
+			c.JSON(http.StatusConflict, response.ResponseError{
+				Success: false,
+				Error:   "API key limit reached, revoke an unused key first",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ResponseError{
+				Success: false,
+				Error:   "Failed to create API key",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, response.ResponseSuccess{
+		Success: true,
+		Message: "API key created. Copy it now, it won't be shown again",
+		Data:    key,
+	})
+}
+
+// RevokeApiKey godoc
+// @Summary      Revoke API key
+// @Description  Revoke an API key of the logged in user. Requests using it are rejected right away
+// @Tags         api-keys
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "API key ID"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /api-keys/{id} [delete]
+func (h *ApiKeyHandler) RevokeApiKey(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid API key id",
+		})
+		return
+	}
+
+	if err := h.apiKeyService.RevokeApiKey(c.Request.Context(), userId, id); err != nil {
+		if err.Error() == "api key not found" {
+			c.JSON(http.StatusNotFound, response.ResponseError{
+				Success: false,
+				Error:   "API key not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to revoke API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "API key revoked successfully",
+	})
+}
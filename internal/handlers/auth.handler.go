@@ -4,7 +4,13 @@ import (
 	"backend-koda-shortlink/internal/models"
 	"backend-koda-shortlink/internal/services"
 	"backend-koda-shortlink/pkg/response"
+	"errors"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
@@ -12,11 +18,13 @@ import (
 
 type AuthHandler struct {
 	authService *services.AuthService
+	frontendURL string
 }
 
-func NewAuthHandler(authService *services.AuthService) *AuthHandler {
+func NewAuthHandler(authService *services.AuthService, frontendURL string) *AuthHandler {
 	return &AuthHandler{
 		authService: authService,
+		frontendURL: frontendURL,
 	}
 }
 
@@ -71,7 +79,7 @@ func (h *AuthHandler) Register(ctx *gin.Context) {
 
 // Login godoc
 // @Summary      Login user
-// @Description  Log in with existing email data
+// @Description  Log in with existing email data. Repeated failures for an account or from an IP make the client wait (429) and eventually lock the account for a while (423), both with a Retry-After header. Accounts with two-factor authentication get twoFactorRequired and a challengeToken instead of the tokens, to send with a code to /auth/2fa/verify
 // @Tags         auth
 // @Accept       x-www-form-urlencoded
 // @Produce      json
@@ -80,6 +88,9 @@ func (h *AuthHandler) Register(ctx *gin.Context) {
 // @Success      200  {object}  response.ResponseSuccess{data=models.LoginResponse}
 // @Failure      400  {object}  response.ResponseError
 // @Failure      401  {object}  response.ResponseError
+// @Failure      403  {object}  response.ResponseError
+// @Failure      423  {object}  response.ResponseError
+// @Failure      429  {object}  response.ResponseError
 // @Failure      500  {object}  response.ResponseError
 // @Router       /auth/login [post]
 func (h *AuthHandler) Login(ctx *gin.Context) {
@@ -97,9 +108,29 @@ func (h *AuthHandler) Login(ctx *gin.Context) {
 
 	loginResp, err := h.authService.Login(ctx.Request.Context(), &req, ipAddress, userAgent)
 	if err != nil {
+		var blocked *services.LoginBlockedError
+		if errors.As(err, &blocked) {
+			statusCode := http.StatusTooManyRequests
+			message := "Too many failed logins, please wait before trying again"
+			if blocked.Locked {
+				statusCode = http.StatusLocked
+				message = "Account temporarily locked after too many failed logins"
+			}
+
+			ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+			ctx.JSON(statusCode, response.ResponseError{
+				Success: false,
+				Error:   message,
+			})
+			return
+		}
+
 		statusCode := http.StatusInternalServerError
-		if err.Error() == "wrong email or password" {
+		switch err.Error() {
+		case "wrong email or password":
 			statusCode = http.StatusUnauthorized
+		case "email not verified":
+			statusCode = http.StatusForbidden
 		}
 
 		ctx.JSON(statusCode, response.ResponseError{
@@ -109,10 +140,85 @@ func (h *AuthHandler) Login(ctx *gin.Context) {
 		return
 	}
 
+	if loginResp.TwoFactorRequired {
+		ctx.JSON(http.StatusOK, response.ResponseSuccess{
+			Success: true,
+			Message: "Two-factor authentication required",
+			Data: gin.H{
+				"twoFactorRequired": true,
+				"challengeToken":    loginResp.ChallengeToken,
+			},
+		})
+		return
+	}
+
 	ctx.SetCookie(
 		"refreshToken",
 		loginResp.RefreshToken,
-		7*24*60*60,
+		int(time.Until(loginResp.RefreshExpiresAt).Seconds()),
+		"/",
+		"",
+		false,
+		true,
+	)
+
+	ctx.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Login successful!",
+		Data: gin.H{
+			"accessToken": loginResp.AccessToken,
+		},
+	})
+}
+
+// VerifyTwoFactor godoc
+// @Summary      Two-factor login
+// @Description  Finish the login of an account with two-factor authentication using the challenge token from login and a code of the authenticator app or a recovery code. At most 5 wrong codes per account are accepted every 15 minutes
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        challengeToken  formData  string  true  "Challenge token from login"
+// @Param        code            formData  string  true  "Authenticator or recovery code"
+// @Success      200  {object}  response.ResponseSuccess{data=object{accessToken=string}}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      429  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /auth/2fa/verify [post]
+func (h *AuthHandler) VerifyTwoFactor(ctx *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := ctx.ShouldBindWith(&req, binding.Form); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide the challenge token and a code",
+		})
+		return
+	}
+
+	loginResp, err := h.authService.VerifyTwoFactorLogin(ctx.Request.Context(), &req, ctx.ClientIP(), ctx.Request.UserAgent())
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		message := "Failed to verify code"
+		switch err.Error() {
+		case "invalid or expired challenge", "invalid code":
+			statusCode = http.StatusUnauthorized
+			message = err.Error()
+		case "too many attempts":
+			statusCode = http.StatusTooManyRequests
+			message = "Too many wrong codes, please try again later"
+		}
+
+		ctx.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	ctx.SetCookie(
+		"refreshToken",
+		loginResp.RefreshToken,
+		int(time.Until(loginResp.RefreshExpiresAt).Seconds()),
 		"/",
 		"",
 		false,
@@ -130,10 +236,10 @@ func (h *AuthHandler) Login(ctx *gin.Context) {
 
 // RefreshToken godoc
 // @Summary      Refresh access token
-// @Description  Get new access token using refresh token from httpOnly cookie
+// @Description  Get new access token using refresh token from httpOnly cookie. The refresh token is rotated and the cookie replaced; reusing an already rotated token revokes all sessions of the user
 // @Tags         auth
 // @Produce      json
-// @Success      200  {object}  response.ResponseSuccess{data=object{access_token=string}}
+// @Success      200  {object}  response.ResponseSuccess{data=object{accessToken=string}}
 // @Failure      400  {object}  response.ResponseError
 // @Failure      401  {object}  response.ResponseError
 // @Failure      500  {object}  response.ResponseError
@@ -152,20 +258,41 @@ func (h *AuthHandler) RefreshToken(ctx *gin.Context) {
 		RefreshToken: refreshToken,
 	}
 
-	accessToken, err := h.authService.RefreshToken(ctx.Request.Context(), &req)
+	refreshResp, err := h.authService.RefreshToken(ctx.Request.Context(), &req)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, response.ResponseError{
+		statusCode := http.StatusInternalServerError
+		message := "Failed to refresh token"
+		switch err.Error() {
+		case "invalid or expired refresh token", "refresh token reuse detected":
+			statusCode = http.StatusUnauthorized
+			message = err.Error()
+			ctx.SetCookie("refreshToken", "", -1, "/", "", false, true)
+		default:
+			log.Printf("[AUTH] refresh failed: %v", err)
+		}
+
+		ctx.JSON(statusCode, response.ResponseError{
 			Success: false,
-			Error:   err.Error(),
+			Error:   message,
 		})
 		return
 	}
 
+	ctx.SetCookie(
+		"refreshToken",
+		refreshResp.RefreshToken,
+		int(time.Until(refreshResp.RefreshExpiresAt).Seconds()),
+		"/",
+		"",
+		false,
+		true,
+	)
+
 	ctx.JSON(http.StatusOK, response.ResponseSuccess{
 		Success: true,
 		Message: "Token refreshed successfully",
 		Data: gin.H{
-			"accessToken": accessToken,
+			"accessToken": refreshResp.AccessToken,
 		},
 	})
 }
@@ -217,3 +344,270 @@ func (h *AuthHandler) Logout(ctx *gin.Context) {
 		Message: "Logout successful",
 	})
 }
+
+// VerifyEmail godoc
+// @Summary      Verify email
+// @Description  Confirm the email address of an account with the token from the verification email
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token  formData  string  true  "Verification token"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /auth/verify-email [post]
+func (h *AuthHandler) VerifyEmail(ctx *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := ctx.ShouldBindWith(&req, binding.Form); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Verification token is required",
+		})
+		return
+	}
+
+	err := h.authService.VerifyEmail(ctx.Request.Context(), &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "invalid or expired token" {
+			statusCode = http.StatusBadRequest
+		}
+
+		ctx.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Email verified successfully",
+	})
+}
+
+// ResendVerification godoc
+// @Summary      Resend verification email
+// @Description  Send a new verification email. Always succeeds so it can't reveal which addresses are registered; at most one email per address and minute is sent
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        email  formData  string  true  "Account email"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Router       /auth/resend-verification [post]
+func (h *AuthHandler) ResendVerification(ctx *gin.Context) {
+	var req models.EmailRequest
+	if err := ctx.ShouldBindWith(&req, binding.Form); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide a valid email",
+		})
+		return
+	}
+
+	if err := h.authService.ResendVerification(ctx.Request.Context(), &req); err != nil {
+		log.Printf("[MAIL] failed to resend verification email: %v", err)
+	}
+
+	ctx.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "If the account exists and is not verified yet, a verification email has been sent",
+	})
+}
+
+// ForgotPassword godoc
+// @Summary      Forgot password
+// @Description  Email a password reset link. Always succeeds so it can't reveal which addresses are registered; at most one email per address and minute is sent
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        email  formData  string  true  "Account email"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Router       /auth/forgot-password [post]
+func (h *AuthHandler) ForgotPassword(ctx *gin.Context) {
+	var req models.EmailRequest
+	if err := ctx.ShouldBindWith(&req, binding.Form); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide a valid email",
+		})
+		return
+	}
+
+	if err := h.authService.ForgotPassword(ctx.Request.Context(), &req); err != nil {
+		log.Printf("[MAIL] failed to send password reset email: %v", err)
+	}
+
+	ctx.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "If the account exists, a password reset email has been sent",
+	})
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Set a new password with the token from the password reset email. All sessions of the account are logged out
+// @Tags         auth
+// @Accept       x-www-form-urlencoded
+// @Produce      json
+// @Param        token     formData  string  true  "Password reset token"
+// @Param        password  formData  string  true  "New password" format(password)
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /auth/reset-password [post]
+func (h *AuthHandler) ResetPassword(ctx *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := ctx.ShouldBindWith(&req, binding.Form); err != nil {
+		ctx.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide the reset token and a new password of at least 8 characters",
+		})
+		return
+	}
+
+	err := h.authService.ResetPassword(ctx.Request.Context(), &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		message := "Failed to reset password"
+		if err.Error() == "invalid or expired token" {
+			statusCode = http.StatusBadRequest
+			message = err.Error()
+		}
+
+		ctx.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Password reset successfully, please login again",
+	})
+}
+
+// OAuthProviders godoc
+// @Summary      List login providers
+// @Description  List the names of the configured external login providers, to render a login button for each
+// @Tags         auth
+// @Produce      json
+// @Success      200  {object}  response.ResponseSuccess{data=[]string}
+// @Router       /auth/oauth [get]
+func (h *AuthHandler) OAuthProviders(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Success get login providers",
+		Data:    h.authService.OAuthProviders(),
+	})
+}
+
+// OAuthLogin godoc
+// @Summary      Login with provider
+// @Description  Redirect the browser to the login page of an external provider such as google or github. The provider sends the user back to the callback endpoint
+// @Tags         auth
+// @Param        provider  path  string  true  "Provider name"
+// @Success      302
+// @Failure      404  {object}  response.ResponseError
+// @Failure      502  {object}  response.ResponseError
+// @Router       /auth/oauth/{provider} [get]
+func (h *AuthHandler) OAuthLogin(ctx *gin.Context) {
+	authURL, state, err := h.authService.StartOAuthLogin(ctx.Request.Context(), ctx.Param("provider"))
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "unknown oauth provider":
+			statusCode = http.StatusNotFound
+		case "oauth provider unavailable":
+			statusCode = http.StatusBadGateway
+		}
+
+		ctx.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// The state has to come back in the same browser, which stops an
+	// attacker from logging a victim into the attacker's account.
+	ctx.SetCookie("oauthState", state, 0, "/api/v1/auth/oauth", "", false, true)
+	ctx.Redirect(http.StatusFound, authURL)
+}
+
+// OAuthCallback godoc
+// @Summary      Provider login callback
+// @Description  Finish a login at an external provider. The identity is linked to the user with the same verified email, or a new user is created. On success the refresh token cookie is set and the browser is redirected to FRONTEND_URL/oauth/callback, where the frontend gets an access token from /auth/refresh. Accounts with two-factor authentication are redirected with a twoFactorChallenge query parameter for /auth/2fa/verify instead. On failure the redirect carries an error query parameter
+// @Tags         auth
+// @Param        provider  path   string  true   "Provider name"
+// @Param        code      query  string  false  "Authorization code"
+// @Param        state     query  string  true   "Login state"
+// @Success      302
+// @Router       /auth/oauth/{provider}/callback [get]
+func (h *AuthHandler) OAuthCallback(ctx *gin.Context) {
+	state, _ := ctx.Cookie("oauthState")
+	ctx.SetCookie("oauthState", "", -1, "/api/v1/auth/oauth", "", false, true)
+
+	if providerErr := ctx.Query("error"); providerErr != "" {
+		h.oauthRedirect(ctx, "access_denied")
+		return
+	}
+
+	if state == "" || state != ctx.Query("state") || ctx.Query("code") == "" {
+		h.oauthRedirect(ctx, "invalid_state")
+		return
+	}
+
+	loginResp, err := h.authService.OAuthLogin(
+		ctx.Request.Context(),
+		ctx.Param("provider"),
+		ctx.Query("code"),
+		state,
+		ctx.ClientIP(),
+		ctx.Request.UserAgent(),
+	)
+	if err != nil {
+		code := "login_failed"
+		switch err.Error() {
+		case "invalid or expired oauth state", "unknown oauth provider":
+			code = "invalid_state"
+		case "oauth email not verified":
+			code = "email_not_verified"
+		default:
+			log.Printf("[OAUTH] login failed: %v", err)
+		}
+		h.oauthRedirect(ctx, code)
+		return
+	}
+
+	if loginResp.TwoFactorRequired {
+		target := h.frontendURL + "/oauth/callback?twoFactorChallenge=" + url.QueryEscape(loginResp.ChallengeToken)
+		ctx.Redirect(http.StatusFound, target)
+		return
+	}
+
+	ctx.SetCookie(
+		"refreshToken",
+		loginResp.RefreshToken,
+		int(time.Until(loginResp.RefreshExpiresAt).Seconds()),
+		"/",
+		"",
+		false,
+		true,
+	)
+
+	h.oauthRedirect(ctx, "")
+}
+
+// oauthRedirect sends the browser back to the frontend, with errorCode when
+// the login failed.
+func (h *AuthHandler) oauthRedirect(ctx *gin.Context, errorCode string) {
+	target := h.frontendURL + "/oauth/callback"
+	if errorCode != "" {
+		target += "?error=" + url.QueryEscape(errorCode)
+	}
+	ctx.Redirect(http.StatusFound, target)
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"backend-koda-shortlink/internal/repository"
 	"backend-koda-shortlink/internal/services"
 	"backend-koda-shortlink/pkg/response"
+	"log"
+	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,22 +23,85 @@ func NewDashboardHandler(dashboardService *services.DashboardService) *Dashboard
 
 // Stats godoc
 // @Summary      Get dashboard statistics
-// @Description  Retrieve user-specific statistics for dashboard overview
+// @Description  Retrieve user-specific statistics for dashboard overview, with a visits chart for the chosen range, granularity and time zone
 // @Tags         dashboard
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        from         query  string  false  "Start of range, YYYY-MM-DD (in timezone) or RFC3339"
+// @Param        to           query  string  false  "End of range, YYYY-MM-DD inclusive (in timezone) or RFC3339 exclusive"
+// @Param        granularity  query  string  false  "Bucket size (hour/day/week/month)" default(day)
+// @Param        timezone     query  string  false  "IANA time zone used for buckets" default(UTC)
 // @Success      200  {object}  response.ResponseSuccess{data=services.DashboardStats}
+// @Failure      400  {object}  response.ResponseError
 // @Failure      500  {object}  response.ResponseError
 // @Router       /dashboard/stats [get]
 func (h *DashboardHandler) Stats(c *gin.Context) {
 	userId := c.GetInt("userId")
 
-	data, err := h.dashboardService.Stats(c.Request.Context(), userId)
+	timezone := c.DefaultQuery("timezone", "UTC")
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "Local" {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid timezone",
+		})
+		return
+	}
+
+	granularity := c.DefaultQuery("granularity", "day")
+	if !services.IsValidGranularity(granularity) {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid granularity, use hour, day, week or month",
+		})
+		return
+	}
+
+	from, to := services.DefaultChartRange(granularity, loc)
+	if f := c.Query("from"); f != "" {
+		parsed, err := parseChartTime(f, loc, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Invalid from date",
+			})
+			return
+		}
+		from = parsed
+	}
+	if t := c.Query("to"); t != "" {
+		parsed, err := parseChartTime(t, loc, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Invalid to date",
+			})
+			return
+		}
+		to = parsed
+	}
+
+	data, err := h.dashboardService.Stats(c.Request.Context(), userId, repository.ChartRange{
+		From:        from,
+		To:          to,
+		Granularity: granularity,
+		Timezone:    loc.String(),
+	})
 	if err != nil {
-		c.JSON(500, response.ResponseError{
+		if err.Error() == "invalid date range" || err.Error() == "date range too large for granularity" {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+
+		log.Printf("[DASHBOARD] stats of user %d: %v", userId, err)
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
 			Success: false,
-			Error:   err.Error(),
+			Error:   "Failed to get dashboard statistics",
 		})
 		return
 	}
@@ -45,3 +112,20 @@ func (h *DashboardHandler) Stats(c *gin.Context) {
 		Data:    data,
 	})
 }
+
+// parseChartTime reads an RFC3339 timestamp or a date in loc. A date used as
+// the end of the range includes that whole day.
+func parseChartTime(value string, loc *time.Location, isEnd bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02", value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if isEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
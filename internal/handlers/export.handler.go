@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/internal/utils"
+	"backend-koda-shortlink/pkg/response"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+func NewExportHandler(exportService *services.ExportService) *ExportHandler {
+	return &ExportHandler{
+		exportService: exportService,
+	}
+}
+
+// ExportLinks godoc
+// @Summary      Export short links
+// @Description  Download the authenticated user's short links as CSV, newline-delimited JSON or XLSX
+// @Tags         exports
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security     BearerAuth
+// @Param        format  query  string  false  "Export format (csv/json/xlsx)" default(csv)
+// @Param        search  query  string  false  "Search query"
+// @Param        status  query  string  false  "Filter by status (active/inactive)"
+// @Success      200  {file}    file
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Router       /exports/links [get]
+func (h *ExportHandler) ExportLinks(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	w, err := startExport(c, format, "links")
+	if err != nil {
+		return
+	}
+
+	err = h.exportService.ExportLinks(c.Request.Context(), userId, c.Query("search"), c.Query("status"), w)
+	if err != nil {
+		log.Printf("[EXPORT] links export for user %d failed: %v", userId, err)
+	}
+}
+
+// ExportClicks godoc
+// @Summary      Export raw clicks
+// @Description  Download the raw click log of the authenticated user's links as CSV, newline-delimited JSON or XLSX, optionally for one short link and a date range
+// @Tags         exports
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Security     BearerAuth
+// @Param        format     query  string  false  "Export format (csv/json/xlsx)" default(csv)
+// @Param        shortCode  query  string  false  "Only export clicks of this short link"
+// @Param        from       query  string  false  "Start of range, RFC3339 or YYYY-MM-DD"
+// @Param        to         query  string  false  "End of range (exclusive for RFC3339, inclusive for YYYY-MM-DD)"
+// @Success      200  {file}    file
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      403  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Router       /exports/clicks [get]
+func (h *ExportHandler) ExportClicks(c *gin.Context) {
+	userId := c.GetInt("userId")
+	shortCode := c.Query("shortCode")
+
+	format, ok := exportFormat(c)
+	if !ok {
+		return
+	}
+
+	from, err := parseRangeTime(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid from date",
+		})
+		return
+	}
+	to, err := parseRangeTime(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid to date",
+		})
+		return
+	}
+
+	if shortCode != "" {
+		if err := h.exportService.CheckLinkOwner(c.Request.Context(), userId, shortCode); err != nil {
+			if err.Error() == "short link not found" {
+				c.JSON(http.StatusNotFound, response.ResponseError{
+					Success: false,
+					Error:   "Short link not found",
+				})
+				return
+			}
+			if err.Error() == "unauthorized access" {
+				c.JSON(http.StatusForbidden, response.ResponseError{
+					Success: false,
+					Error:   "Access denied",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, response.ResponseError{
+				Success: false,
+				Error:   "Failed to export clicks",
+			})
+			return
+		}
+	}
+
+	w, err := startExport(c, format, "clicks")
+	if err != nil {
+		return
+	}
+
+	err = h.exportService.ExportClicks(c.Request.Context(), userId, shortCode, from, to, w)
+	if err != nil {
+		log.Printf("[EXPORT] clicks export for user %d failed: %v", userId, err)
+	}
+}
+
+func exportFormat(c *gin.Context) (string, bool) {
+	format := c.DefaultQuery("format", "csv")
+	if !utils.IsValidExportFormat(format) {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid format, use csv, json or xlsx",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// startExport writes the download headers and returns a row writer on the
+// response body. Once it returns, errors can only be logged.
+func startExport(c *gin.Context, format, name string) (utils.RowWriter, error) {
+	w, err := utils.NewRowWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to start export",
+		})
+		return nil, err
+	}
+
+	filename := utils.ExportFilename(name+"-"+time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", utils.ExportContentType(format))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	return w, nil
+}
+
+// parseRangeTime accepts RFC3339 timestamps or plain dates. A plain date used
+// as the end of a range covers the whole day.
+func parseRangeTime(value string, isEnd bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		t = t.UTC()
+		return &t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+	if isEnd {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/pkg/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QRCodeHandler struct {
+	qrCodeService *services.QRCodeService
+}
+
+func NewQRCodeHandler(qrCodeService *services.QRCodeService) *QRCodeHandler {
+	return &QRCodeHandler{
+		qrCodeService: qrCodeService,
+	}
+}
+
+// QRCode godoc
+// @Summary      Get short link QR code
+// @Description  Render the short URL of a link as a QR code image. With a logo the error correction level defaults to h and has to be q or h.
+// @Tags         links
+// @Produce      png
+// @Produce      image/svg+xml
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        shortCode  path   string  true   "Short code"
+// @Param        format     query  string  false  "Image format (png or svg)" default(png)
+// @Param        size       query  int     false  "Width and height in pixels (64-2048)" default(256)
+// @Param        margin     query  int     false  "Quiet zone in modules (0-16)" default(4)
+// @Param        level      query  string  false  "Error correction level (l, m, q or h)" default(m)
+// @Param        fg         query  string  false  "Foreground color as hex" default(000000)
+// @Param        bg         query  string  false  "Background color as hex" default(ffffff)
+// @Param        logo       query  string  false  "URL of a PNG, JPEG, GIF or WebP logo drawn in the center"
+// @Success      200  {file}    file
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      403  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /links/{shortCode}/qr [get]
+func (h *QRCodeHandler) QRCode(c *gin.Context) {
+	userId := c.GetInt("userId")
+	shortCode := c.Param("shortCode")
+
+	var opts models.QRCodeOptions
+	if err := c.ShouldBindQuery(&opts); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid query parameters",
+		})
+		return
+	}
+
+	data, contentType, err := h.qrCodeService.QRCode(c.Request.Context(), userId, shortCode, opts)
+	if err != nil {
+		switch err.Error() {
+		case "invalid qr format", "invalid qr size", "invalid qr margin", "invalid error correction level",
+			"logo requires error correction level q or h", "invalid color", "invalid logo url",
+			"failed to load logo", "qr size too small":
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   err.Error(),
+			})
+		case "short link not found":
+			c.JSON(http.StatusNotFound, response.ResponseError{
+				Success: false,
+				Error:   "Short link not found",
+			})
+		case "unauthorized access":
+			c.JSON(http.StatusForbidden, response.ResponseError{
+				Success: false,
+				Error:   "Access denied",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ResponseError{
+				Success: false,
+				Error:   "Failed to generate QR code",
+			})
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=3600")
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/pkg/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SessionHandler struct {
+	sessionService *services.SessionService
+}
+
+func NewSessionHandler(sessionService *services.SessionService) *SessionHandler {
+	return &SessionHandler{
+		sessionService: sessionService,
+	}
+}
+
+// ListSessions godoc
+// @Summary      List active sessions
+// @Description  List the active sessions of the logged in user with device, IP address and last seen time. The session of the current access token is marked as current
+// @Tags         sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  response.ResponseSuccess{data=[]models.SessionInfo}
+// @Failure      401  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /sessions [get]
+func (h *SessionHandler) ListSessions(c *gin.Context) {
+	userId := c.GetInt("userId")
+	sessionId := c.GetInt("sessionId")
+
+	sessions, err := h.sessionService.ListSessions(c.Request.Context(), userId, sessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to get sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Success get sessions",
+		Data:    sessions,
+	})
+}
+
+// RevokeSession godoc
+// @Summary      Revoke session
+// @Description  Log out one session of the logged in user, for example a lost device
+// @Tags         sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Param        id  path  int  true  "Session ID"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /sessions/{id} [delete]
+func (h *SessionHandler) RevokeSession(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	sessionId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid session id",
+		})
+		return
+	}
+
+	err = h.sessionService.RevokeSession(c.Request.Context(), userId, sessionId)
+	if err != nil {
+		if err.Error() == "session not found" {
+			c.JSON(http.StatusNotFound, response.ResponseError{
+				Success: false,
+				Error:   "Session not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to revoke session",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Session revoked successfully",
+	})
+}
+
+// RevokeOtherSessions godoc
+// @Summary      Log out other devices
+// @Description  Log out every session of the logged in user except the one of the current access token
+// @Tags         sessions
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  response.ResponseSuccess{data=object{revoked=int}}
+// @Failure      401  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /sessions [delete]
+func (h *SessionHandler) RevokeOtherSessions(c *gin.Context) {
+	userId := c.GetInt("userId")
+	sessionId := c.GetInt("sessionId")
+
+	revoked, err := h.sessionService.RevokeOtherSessions(c.Request.Context(), userId, sessionId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to revoke sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Logged out from all other devices",
+		Data: gin.H{
+			"revoked": revoked,
+		},
+	})
+}
@@ -4,33 +4,53 @@ import (
 	"backend-koda-shortlink/internal/models"
 	"backend-koda-shortlink/internal/services"
 	"backend-koda-shortlink/pkg/response"
+	"encoding/csv"
+	"html/template"
+	"io"
 	"net/http"
-	"os"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ShortLinkHandler struct {
 	service *services.ShortLinkService
+	appURL  string
 }
 
-func NewShortLinkHandler(service *services.ShortLinkService) *ShortLinkHandler {
-	return &ShortLinkHandler{service: service}
+func NewShortLinkHandler(service *services.ShortLinkService, appURL string) *ShortLinkHandler {
+	return &ShortLinkHandler{service: service, appURL: appURL}
+}
+
+// urlPolicyErrors are the reasons the URL policy refuses a destination.
+var urlPolicyErrors = map[string]bool{
+	"invalid url":                     true,
+	"url scheme not allowed":          true,
+	"url points to this shortener":    true,
+	"domain is not allowed":           true,
+	"domain is blocked":               true,
+	"url points to a private network": true,
+	"url is flagged as unsafe":        true,
 }
 
 // CreateShortLink godoc
 // @Summary      Create short link
-// @Description  Create a new short link with auto-generated code
+// @Description  Create a new short link with auto-generated code, or a custom alias (3-20 chars of letters, digits, "-" and "_") for authenticated users. Anonymous requests have to solve the challenge from /links/challenge, are limited per IP and expire unless claimed with the returned claimToken.
 // @Tags         links
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Param        request  body  models.CreateShortLinkRequest  true  "Short link details"
 // @Success      201  {object}  response.ResponseSuccess
 // @Failure      400  {object}  response.ResponseError
 // @Failure      401  {object}  response.ResponseError
+// @Failure      403  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      429  {object}  response.ResponseError
 // @Failure      500  {object}  response.ResponseError
+// @Failure      503  {object}  response.ResponseError
 // @Router       /links [post]
 func (h *ShortLinkHandler) CreateShortLink(c *gin.Context) {
 	userId := c.GetInt("userId")
@@ -44,9 +64,29 @@ func (h *ShortLinkHandler) CreateShortLink(c *gin.Context) {
 		return
 	}
 
-	link, err := h.service.CreateShortLink(c.Request.Context(), userId, &req)
+	link, err := h.service.CreateShortLink(c.Request.Context(), userId, c.ClientIP(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, response.ResponseError{
+		statusCode := http.StatusInternalServerError
+		switch err.Error() {
+		case "invalid alias", "alias is reserved", "expiration must be in the future", "max clicks must be greater than zero",
+			"link password must be at least 4 characters":
+			statusCode = http.StatusBadRequest
+		case "login required to use custom alias", "login required to protect link with password":
+			statusCode = http.StatusUnauthorized
+		case "verification required", "verification failed":
+			statusCode = http.StatusForbidden
+		case "short code already in use":
+			statusCode = http.StatusConflict
+		case "anonymous link quota reached":
+			statusCode = http.StatusTooManyRequests
+		case "verification unavailable":
+			statusCode = http.StatusServiceUnavailable
+		}
+		if urlPolicyErrors[err.Error()] {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, response.ResponseError{
 			Success: false,
 			Error:   err.Error(),
 		})
@@ -57,13 +97,234 @@ func (h *ShortLinkHandler) CreateShortLink(c *gin.Context) {
 		Success: true,
 		Message: "Short link created successfully",
 		Data: models.ShortLinkResponse{
-			ShortCode:   link.ShortCode,
-			OriginalUrl: link.OriginalURL,
-			ShortUrl:    os.Getenv("APP_URL") + link.ShortCode,
+			ShortCode:    link.ShortCode,
+			OriginalUrl:  link.OriginalURL,
+			ShortUrl:     h.appURL + link.ShortCode,
+			ExpiresAt:    link.ExpiredAt,
+			MaxClicks:    link.MaxClicks,
+			IsProtected:  link.IsProtected,
+			ClaimToken:   link.ClaimToken,
+			UTM:          utmResponse(link.UTM),
+			ForwardQuery: link.ForwardQuery,
+		},
+	})
+}
+
+// utmResponse leaves the UTM fields out of responses when none is set.
+func utmResponse(utm models.UTMParams) *models.UTMParams {
+	if utm == (models.UTMParams{}) {
+		return nil
+	}
+	return &utm
+}
+
+// AnonymousChallenge godoc
+// @Summary      Get link creation challenge
+// @Description  Get the challenge anonymous clients have to solve before creating a link. For "pow" find a nonce so that the SHA-256 hash of "<challenge>:<nonce>" starts with difficulty zero bits and send "<challenge>:<nonce>" as challengeResponse. For captcha types render the widget with siteKey and send its token.
+// @Tags         links
+// @Produce      json
+// @Success      200  {object}  response.ResponseSuccess{data=models.AnonymousChallenge}
+// @Failure      500  {object}  response.ResponseError
+// @Router       /links/challenge [get]
+func (h *ShortLinkHandler) AnonymousChallenge(c *gin.Context) {
+	challenge, err := h.service.AnonymousChallenge(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to create challenge",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Challenge created successfully",
+		Data:    challenge,
+	})
+}
+
+// ClaimShortLinks godoc
+// @Summary      Claim anonymous short links
+// @Description  Take over links created anonymously, identified by the claim tokens returned when they were created. Claimed links no longer expire automatically. Unknown, already claimed and expired tokens are skipped.
+// @Tags         links
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        request  body  models.ClaimShortLinksRequest  true  "Claim tokens"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /links/claim [post]
+func (h *ShortLinkHandler) ClaimShortLinks(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.ClaimShortLinksRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+		return
+	}
+
+	codes, err := h.service.ClaimShortLinks(c.Request.Context(), userId, req.ClaimTokens)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to claim links",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Links claimed successfully",
+		Data: gin.H{
+			"claimed":    len(codes),
+			"shortCodes": codes,
+		},
+	})
+}
+
+// CreateShortLinksBulk godoc
+// @Summary      Create short links in bulk
+// @Description  Create up to 1000 short links at once from a JSON array or an uploaded CSV file (columns: url, alias). The result of every row is reported separately.
+// @Tags         links
+// @Accept       json
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Security     ApiKeyAuth
+// @Param        request  body      []models.BulkShortLinkItem  false  "Links to create"
+// @Param        file     formData  file                        false  "CSV file with url and optional alias columns"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /links/bulk [post]
+func (h *ShortLinkHandler) CreateShortLinksBulk(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var items []models.BulkShortLinkItem
+	if strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "CSV file is required",
+			})
+			return
+		}
+
+		file, err := fileHeader.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Failed to read CSV file",
+			})
+			return
+		}
+		defer file.Close()
+
+		items, err = parseBulkCSV(file)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Invalid CSV file",
+			})
+			return
+		}
+	} else if err := c.ShouldBindJSON(&items); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Invalid request body",
+		})
+		return
+	}
+
+	results, err := h.service.CreateShortLinksBulk(c.Request.Context(), userId, items)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		if err.Error() == "no links to create" || err.Error() == "too many links in one request" {
+			statusCode = http.StatusBadRequest
+		}
+
+		c.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	succeeded := 0
+	for i := range results {
+		if results[i].Success {
+			results[i].ShortUrl = h.appURL + results[i].ShortCode
+			succeeded++
+		}
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Bulk short link creation finished",
+		Data: gin.H{
+			"total":     len(results),
+			"succeeded": succeeded,
+			"failed":    len(results) - succeeded,
+			"results":   results,
 		},
 	})
 }
 
+// parseBulkCSV reads url and alias columns. A header row is optional; without
+// one the first column is the url and the second the alias.
+func parseBulkCSV(r io.Reader) ([]models.BulkShortLinkItem, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	urlCol, aliasCol := 0, 1
+	if len(records) > 0 {
+		header := make(map[string]int)
+		for i, col := range records[0] {
+			col = strings.TrimPrefix(col, "\ufeff")
+			header[strings.ToLower(strings.TrimSpace(col))] = i
+		}
+		for _, name := range []string{"url", "originalurl", "original_url"} {
+			if i, ok := header[name]; ok {
+				urlCol = i
+				aliasCol = -1
+				if j, ok := header["alias"]; ok {
+					aliasCol = j
+				}
+				records = records[1:]
+				break
+			}
+		}
+	}
+
+	items := make([]models.BulkShortLinkItem, 0, len(records))
+	for _, record := range records {
+		var item models.BulkShortLinkItem
+		if urlCol < len(record) {
+			item.OriginalURL = record[urlCol]
+		}
+		if aliasCol >= 0 && aliasCol < len(record) {
+			item.Alias = record[aliasCol]
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
 // GetAllLinks godoc
 // @Summary      Get all short links
 // @Description  Get all short links created by authenticated user with filters
@@ -71,6 +332,7 @@ func (h *ShortLinkHandler) CreateShortLink(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Param        page     query  int     false  "Page number" default(1)
 // @Param        limit    query  int     false  "Items per page" default(10)
 // @Param        search   query  string  false  "Search query"
@@ -108,18 +370,22 @@ func (h *ShortLinkHandler) GetAllLinks(c *gin.Context) {
 		return
 	}
 
-	appURL := os.Getenv("APP_URL")
 	linkResponses := make([]map[string]interface{}, len(links))
 	for i, link := range links {
 		linkResponses[i] = map[string]any{
 			"id":             link.ID,
 			"userId":         link.UserID,
 			"shortCode":      link.ShortCode,
-			"shortUrl":       appURL + link.ShortCode,
+			"shortUrl":       h.appURL + link.ShortCode,
 			"originalUrl":    link.OriginalURL,
 			"isActive":       link.IsActive,
 			"clickCount":     link.ClickCount,
 			"lastClicked_at": link.LastClickedAt,
+			"expiresAt":      link.ExpiredAt,
+			"maxClicks":      link.MaxClicks,
+			"isProtected":    link.IsProtected,
+			"utm":            link.UTM,
+			"forwardQuery":   link.ForwardQuery,
 			"createdAt":      link.CreatedAt,
 			"updatedAt":      link.UpdatedAt,
 			"createdBy":      link.CreatedBy,
@@ -149,6 +415,7 @@ func (h *ShortLinkHandler) GetAllLinks(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Param        shortCode  path  string  true  "Short code"
 // @Success      200  {object}  response.ResponseSuccess
 // @Failure      401  {object}  response.ResponseError
@@ -192,11 +459,12 @@ func (h *ShortLinkHandler) GetLinkByShortCode(c *gin.Context) {
 
 // UpdateShortLink godoc
 // @Summary      Update short link
-// @Description  Update short link details (original URL and/or active status)
+// @Description  Update short link details (original URL, active status, expiration date, click limit, password, UTM fields and/or query forwarding). An empty password removes the protection, utm replaces all UTM fields, clearExpiresAt and clearMaxClicks remove the expiration date and the click limit.
 // @Tags         links
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Param        shortCode  path  string  true  "Short code"
 // @Param        request  body  models.UpdateShortLinkRequest  true  "Update details"
 // @Success      200  {object}  response.ResponseSuccess
@@ -221,6 +489,15 @@ func (h *ShortLinkHandler) UpdateShortLink(c *gin.Context) {
 
 	link, err := h.service.UpdateShortLink(c.Request.Context(), shortCode, userId, &req)
 	if err != nil {
+		if err.Error() == "expiration must be in the future" || err.Error() == "max clicks must be greater than zero" ||
+			err.Error() == "cannot set and clear a limit at once" ||
+			err.Error() == "link password must be at least 4 characters" || urlPolicyErrors[err.Error()] {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
 		if err.Error() == "short link not found" {
 			c.JSON(http.StatusNotFound, response.ResponseError{
 				Success: false,
@@ -256,6 +533,7 @@ func (h *ShortLinkHandler) UpdateShortLink(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Security     BearerAuth
+// @Security     ApiKeyAuth
 // @Param        shortCode  path  string  true  "Short code"
 // @Success      200  {object}  response.ResponseSuccess
 // @Failure      401  {object}  response.ResponseError
@@ -302,6 +580,20 @@ func (h *ShortLinkHandler) Redirect(c *gin.Context) {
 
 	link, err := h.service.ResolveShortCode(c.Request.Context(), code)
 	if err != nil {
+		if err.Error() == "short link expired" || err.Error() == "short link click limit reached" {
+			c.JSON(http.StatusGone, response.ResponseError{
+				Success: false,
+				Error:   "Short link is no longer available",
+			})
+			return
+		}
+		if err.Error() == "short link blocked" {
+			c.JSON(http.StatusForbidden, response.ResponseError{
+				Success: false,
+				Error:   "Short link has been blocked",
+			})
+			return
+		}
 		c.JSON(http.StatusNotFound, response.ResponseError{
 			Success: false,
 			Error:   "Short link not found",
@@ -309,9 +601,139 @@ func (h *ShortLinkHandler) Redirect(c *gin.Context) {
 		return
 	}
 
-	go h.service.LogClick(code)
+	if link.IsProtected {
+		token, err := c.Cookie(unlockCookieName(code))
+		if err != nil || !h.service.IsUnlocked(c.Request.Context(), token, link) {
+			h.passwordChallenge(c, http.StatusUnauthorized, code, "")
+			return
+		}
+	}
+
+	if err := h.service.ReserveClick(c.Request.Context(), link); err != nil {
+		if err.Error() == "short link click limit reached" {
+			c.JSON(http.StatusGone, response.ResponseError{
+				Success: false,
+				Error:   "Short link is no longer available",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to redirect",
+		})
+		return
+	}
+
+	h.service.RecordClick(c.Request, link)
+
+	c.Redirect(http.StatusTemporaryRedirect, h.service.Destination(link, c.Request.URL.RawQuery))
+}
+
+var unlockFormTemplate = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Protected link</title>
+</head>
+<body>
+	<form method="post" action="/{{.ShortCode}}/unlock{{.Query}}">
+		<p>This link is password protected.</p>
+		{{if .Error}}<p style="color:#c00">{{.Error}}</p>{{end}}
+		<input type="password" name="password" placeholder="Password" autofocus required>
+		<button type="submit">Unlock</button>
+	</form>
+</body>
+</html>`))
+
+func unlockCookieName(shortCode string) string {
+	return "unlock_" + shortCode
+}
+
+// unlockQuery carries the query string of the visit through the unlock form,
+// so links forwarding it still get it after unlocking.
+func unlockQuery(c *gin.Context) template.URL {
+	if c.Request.URL.RawQuery == "" {
+		return ""
+	}
+	return template.URL("?" + c.Request.URL.RawQuery)
+}
+
+// passwordChallenge answers a request for a protected link with an HTML unlock
+// form for browsers and a JSON error for API clients.
+func (h *ShortLinkHandler) passwordChallenge(c *gin.Context, statusCode int, shortCode, message string) {
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEHTML {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(statusCode)
+		unlockFormTemplate.Execute(c.Writer, gin.H{
+			"ShortCode": shortCode,
+			"Query":     unlockQuery(c),
+			"Error":     message,
+		})
+		return
+	}
+
+	if message == "" {
+		message = "Password required to open this link"
+	}
+	c.JSON(statusCode, response.ResponseError{
+		Success: false,
+		Error:   message,
+	})
+}
 
-	h.service.SaveClickAnalytics(c.Request, link)
+// UnlockShortLink verifies the password of a protected link submitted from
+// the unlock form (or as JSON) and sets a short-lived cookie for the redirect.
+func (h *ShortLinkHandler) UnlockShortLink(c *gin.Context) {
+	code := c.Param("shortCode")
 
-	c.Redirect(http.StatusTemporaryRedirect, link.OriginalURL)
+	var req models.UnlockShortLinkRequest
+	if err := c.ShouldBind(&req); err != nil {
+		h.passwordChallenge(c, http.StatusBadRequest, code, "Please enter the password")
+		return
+	}
+
+	token, ttl, err := h.service.UnlockShortLink(c.Request.Context(), code, req.Password, c.ClientIP())
+	if err != nil {
+		switch err.Error() {
+		case "wrong link password":
+			h.passwordChallenge(c, http.StatusUnauthorized, code, "Wrong password")
+		case "too many unlock attempts":
+			h.passwordChallenge(c, http.StatusTooManyRequests, code, "Too many attempts, please try again later")
+		case "short link not found", "short link is not protected":
+			c.JSON(http.StatusNotFound, response.ResponseError{
+				Success: false,
+				Error:   "Short link not found",
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, response.ResponseError{
+				Success: false,
+				Error:   "Failed to unlock link",
+			})
+		}
+		return
+	}
+
+	c.SetCookie(
+		unlockCookieName(code),
+		token,
+		int(ttl.Seconds()),
+		"/"+code,
+		"",
+		false,
+		true,
+	)
+
+	if c.NegotiateFormat(gin.MIMEHTML, gin.MIMEJSON) == gin.MIMEHTML {
+		c.Redirect(http.StatusSeeOther, "/"+code+string(unlockQuery(c)))
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Link unlocked successfully",
+		Data: gin.H{
+			"shortUrl": h.appURL + code,
+		},
+	})
 }
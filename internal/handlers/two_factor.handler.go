@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/pkg/response"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TwoFactorHandler struct {
+	twoFactorService *services.TwoFactorService
+}
+
+func NewTwoFactorHandler(twoFactorService *services.TwoFactorService) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		twoFactorService: twoFactorService,
+	}
+}
+
+// twoFactorError maps the errors of the two-factor service to a response.
+func twoFactorError(c *gin.Context, err error, fallback string) {
+	statusCode := http.StatusInternalServerError
+	message := fallback
+	switch err.Error() {
+	case "wrong password", "invalid code":
+		statusCode = http.StatusUnauthorized
+		message = err.Error()
+	case "two-factor already enabled", "two-factor not enabled", "two-factor enrollment not started":
+		statusCode = http.StatusConflict
+		message = err.Error()
+	case "too many attempts":
+		statusCode = http.StatusTooManyRequests
+		message = "Too many wrong codes, please try again later"
+	}
+
+	c.JSON(statusCode, response.ResponseError{
+		Success: false,
+		Error:   message,
+	})
+}
+
+// Status godoc
+// @Summary      Two-factor status
+// @Description  Show whether two-factor authentication is enabled for the logged in user and how many recovery codes are left
+// @Tags         two-factor
+// @Produce      json
+// @Security     BearerAuth
+// @Success      200  {object}  response.ResponseSuccess{data=models.TwoFactorStatus}
+// @Failure      401  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/2fa [get]
+func (h *TwoFactorHandler) Status(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	status, err := h.twoFactorService.Status(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to get two-factor status",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Success get two-factor status",
+		Data:    status,
+	})
+}
+
+// Enroll godoc
+// @Summary      Start two-factor enrollment
+// @Description  Create a TOTP secret for an authenticator app, returned as otpauth URI and QR code. Two-factor authentication is only turned on by /users/2fa/confirm
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.TwoFactorEnrollRequest  true  "Current password"
+// @Success      200  {object}  response.ResponseSuccess{data=models.TwoFactorEnrollment}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/2fa/enroll [post]
+func (h *TwoFactorHandler) Enroll(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.TwoFactorEnrollRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide your current password",
+		})
+		return
+	}
+
+	enrollment, err := h.twoFactorService.Enroll(c.Request.Context(), userId, &req)
+	if err != nil {
+		twoFactorError(c, err, "Failed to start two-factor enrollment")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Scan the QR code with your authenticator app and confirm with a code",
+		Data:    enrollment,
+	})
+}
+
+// Confirm godoc
+// @Summary      Confirm two-factor enrollment
+// @Description  Turn on two-factor authentication with a code of the authenticator app. Returns the recovery codes, which are only shown once. All other sessions are logged out
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.TwoFactorCodeRequest  true  "Authenticator code"
+// @Success      200  {object}  response.ResponseSuccess{data=models.RecoveryCodesResponse}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      429  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/2fa/confirm [post]
+func (h *TwoFactorHandler) Confirm(c *gin.Context) {
+	userId := c.GetInt("userId")
+	sessionId := c.GetInt("sessionId")
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide a code",
+		})
+		return
+	}
+
+	codes, err := h.twoFactorService.Confirm(c.Request.Context(), userId, sessionId, &req)
+	if err != nil {
+		twoFactorError(c, err, "Failed to enable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Two-factor authentication enabled. Store the recovery codes safely, they won't be shown again",
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      New recovery codes
+// @Description  Replace all recovery codes with new ones. The old codes stop working
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.TwoFactorCodeRequest  true  "Authenticator or recovery code"
+// @Success      200  {object}  response.ResponseSuccess{data=models.RecoveryCodesResponse}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      429  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/2fa/recovery-codes [post]
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide a code",
+		})
+		return
+	}
+
+	codes, err := h.twoFactorService.RegenerateRecoveryCodes(c.Request.Context(), userId, &req)
+	if err != nil {
+		twoFactorError(c, err, "Failed to create recovery codes")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "New recovery codes created, they won't be shown again",
+		Data:    models.RecoveryCodesResponse{RecoveryCodes: codes},
+	})
+}
+
+// Disable godoc
+// @Summary      Disable two-factor authentication
+// @Description  Turn off two-factor authentication with the current password and a code of the authenticator app or a recovery code
+// @Tags         two-factor
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.TwoFactorDisableRequest  true  "Current password and code"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      401  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      429  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/2fa [delete]
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide your current password and a code",
+		})
+		return
+	}
+
+	if err := h.twoFactorService.Disable(c.Request.Context(), userId, &req); err != nil {
+		twoFactorError(c, err, "Failed to disable two-factor authentication")
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Two-factor authentication disabled",
+	})
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"backend-koda-shortlink/internal/models"
 	"backend-koda-shortlink/internal/services"
 	"backend-koda-shortlink/pkg/response"
+	"errors"
+	"io"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -45,3 +49,260 @@ func (h *UserHandler) GetUserDetail(c *gin.Context) {
 		Data:    user,
 	})
 }
+
+// UpdateProfile godoc
+// @Summary      Update user profile
+// @Description  Change the full name and/or email of the logged in user. Omitted fields are left unchanged
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.UpdateProfileRequest  true  "Profile fields to change"
+// @Success      200  {object}  response.ResponseSuccess{data=models.User}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      409  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users [patch]
+func (h *UserHandler) UpdateProfile(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide a valid full name or email",
+		})
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(c.Request.Context(), userId, &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		message := "Failed to update profile"
+		switch err.Error() {
+		case "nothing to update", "full name cannot be empty":
+			statusCode = http.StatusBadRequest
+			message = err.Error()
+		case "email already registered":
+			statusCode = http.StatusConflict
+			message = err.Error()
+		case "user not found":
+			statusCode = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Profile updated successfully",
+		Data:    user,
+	})
+}
+
+// ChangePassword godoc
+// @Summary      Change password
+// @Description  Change the password of the logged in user after verifying the current one. All other sessions are logged out
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.ChangePasswordRequest  true  "Current and new password"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/password [patch]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userId := c.GetInt("userId")
+	sessionId := c.GetInt("sessionId")
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide the current password and a new password of at least 8 characters",
+		})
+		return
+	}
+
+	err := h.userService.ChangePassword(c.Request.Context(), userId, sessionId, &req)
+	if err != nil {
+		if err.Error() == "wrong password" {
+			c.JSON(http.StatusBadRequest, response.ResponseError{
+				Success: false,
+				Error:   "Current password is incorrect",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, response.ResponseError{
+			Success: false,
+			Error:   "Failed to change password",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Password changed successfully",
+	})
+}
+
+// DeleteAccount godoc
+// @Summary      Delete account
+// @Description  Permanently delete the logged in user after verifying the password. Short links are either deleted with their clicks ("delete") or kept working without an owner ("anonymize")
+// @Tags         users
+// @Accept       json
+// @Produce      json
+// @Security     BearerAuth
+// @Param        request  body  models.DeleteAccountRequest  true  "Password and what to do with the links"
+// @Success      200  {object}  response.ResponseSuccess
+// @Failure      400  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users [delete]
+func (h *UserHandler) DeleteAccount(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please provide your password and links as \"delete\" or \"anonymize\"",
+		})
+		return
+	}
+
+	err := h.userService.DeleteAccount(c.Request.Context(), userId, &req)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		message := "Failed to delete account"
+		switch err.Error() {
+		case "wrong password":
+			statusCode = http.StatusBadRequest
+			message = "Password is incorrect"
+		case "user not found":
+			statusCode = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	c.SetCookie("refreshToken", "", -1, "/", "", false, true)
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Account deleted successfully",
+	})
+}
+
+// UploadProfilePhoto godoc
+// @Summary      Upload profile photo
+// @Description  Upload a JPEG, PNG, GIF or WebP image of at most 5 MB. It is cropped to a square and stored in 64, 128, 256 and 512 pixel sizes; the 512 pixel URL becomes the profile photo
+// @Tags         users
+// @Accept       multipart/form-data
+// @Produce      json
+// @Security     BearerAuth
+// @Param        photo  formData  file  true  "Image file"
+// @Success      200  {object}  response.ResponseSuccess{data=object{profilePhoto=string,sizes=map[string]string}}
+// @Failure      400  {object}  response.ResponseError
+// @Failure      404  {object}  response.ResponseError
+// @Failure      413  {object}  response.ResponseError
+// @Failure      415  {object}  response.ResponseError
+// @Failure      500  {object}  response.ResponseError
+// @Router       /users/photo [put]
+func (h *UserHandler) UploadProfilePhoto(c *gin.Context) {
+	userId := c.GetInt("userId")
+
+	// Leave room for the multipart framing around the file itself.
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxProfilePhotoSize+1<<20)
+
+	fileHeader, err := c.FormFile("photo")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, response.ResponseError{
+				Success: false,
+				Error:   "Profile photo must be at most 5 MB",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Please upload an image in the photo field",
+		})
+		return
+	}
+
+	if fileHeader.Size > services.MaxProfilePhotoSize {
+		c.JSON(http.StatusRequestEntityTooLarge, response.ResponseError{
+			Success: false,
+			Error:   "Profile photo must be at most 5 MB",
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Failed to read uploaded file",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxProfilePhotoSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, response.ResponseError{
+			Success: false,
+			Error:   "Failed to read uploaded file",
+		})
+		return
+	}
+
+	sizes, err := h.userService.UploadProfilePhoto(c.Request.Context(), userId, data)
+	if err != nil {
+		statusCode := http.StatusInternalServerError
+		message := "Failed to upload profile photo"
+		switch err.Error() {
+		case "profile photo too large":
+			statusCode = http.StatusRequestEntityTooLarge
+			message = "Profile photo must be at most 5 MB"
+		case "unsupported image type":
+			statusCode = http.StatusUnsupportedMediaType
+			message = "Only JPEG, PNG, GIF and WebP images are supported"
+		case "invalid image", "image dimensions too large":
+			statusCode = http.StatusBadRequest
+			message = err.Error()
+		case "user not found":
+			statusCode = http.StatusNotFound
+			message = "User not found"
+		}
+
+		c.JSON(statusCode, response.ResponseError{
+			Success: false,
+			Error:   message,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response.ResponseSuccess{
+		Success: true,
+		Message: "Profile photo updated successfully",
+		Data: gin.H{
+			"profilePhoto": sizes["512"],
+			"sizes":        sizes,
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package mailer
+
+import (
+	"context"
+	"log"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// LogMailer is meant for local development. It prints every message to the
+// log and, when dir is set, also writes it there as an .eml file.
+type LogMailer struct {
+	from *mail.Address
+	dir  string
+}
+
+func NewLogMailer(from *mail.Address, dir string) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &LogMailer{from: from, dir: dir}, nil
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	log.Printf("[MAIL] to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	data, err := buildMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	name := strconv.FormatInt(time.Now().UnixNano(), 10) + ".eml"
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o644)
+}
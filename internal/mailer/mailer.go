@@ -0,0 +1,76 @@
+package mailer
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers plain text emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Open returns the Mailer selected by cfg.Driver.
+func Open(cfg config.MailConfig) (Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAIL_FROM %q: %w", cfg.From, err)
+	}
+
+	switch cfg.Driver {
+	case "log":
+		return NewLogMailer(from, cfg.Dir)
+	case "smtp":
+		return NewSMTPMailer(from, cfg.SMTP), nil
+	}
+	return nil, errors.New("unsupported mail driver " + cfg.Driver)
+}
+
+// buildMessage renders msg as an RFC 5322 message with a quoted-printable
+// UTF-8 body.
+func buildMessage(from *mail.Address, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	qp := quotedprintable.NewWriter(&body)
+	if _, err := qp.Write([]byte(msg.Body)); err != nil {
+		return nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, err
+	}
+
+	domain := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	headers := [][2]string{
+		{"From", from.String()},
+		{"To", msg.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", "<" + rand.Text() + "@" + domain + ">"},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "quoted-printable"},
+	}
+	for _, h := range headers {
+		buf.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+
+	return buf.Bytes(), nil
+}
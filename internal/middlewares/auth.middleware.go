@@ -4,7 +4,9 @@ import (
 	"backend-koda-shortlink/internal/repository"
 	"backend-koda-shortlink/internal/utils"
 	"backend-koda-shortlink/pkg/response"
+	"log"
 	"net/http"
+	"slices"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -13,16 +15,39 @@ import (
 
 type AuthMiddleware struct {
 	sessionRepo *repository.SessionRepository
+	apiKeyRepo  *repository.ApiKeyRepository
+	appSecret   string
 }
 
-func NewAuthMiddleware(sessionRepo *repository.SessionRepository) *AuthMiddleware {
+func NewAuthMiddleware(sessionRepo *repository.SessionRepository, apiKeyRepo *repository.ApiKeyRepository, appSecret string) *AuthMiddleware {
 	return &AuthMiddleware{
 		sessionRepo: sessionRepo,
+		apiKeyRepo:  apiKeyRepo,
+		appSecret:   appSecret,
 	}
 }
 
-func (m *AuthMiddleware) Auth() gin.HandlerFunc {
+// Auth requires a valid access token. When scopes are given the route also
+// accepts an API key, sent as "Authorization: ApiKey <key>" or in the
+// X-API-Key header, that has every one of those scopes. Routes without scopes
+// stay reserved for logged in users.
+func (m *AuthMiddleware) Auth(scopes ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if key, ok := apiKeyFromRequest(ctx); ok {
+			if len(scopes) == 0 {
+				ctx.JSON(http.StatusForbidden, response.ResponseError{
+					Success: false,
+					Error:   "API keys can't be used for this endpoint",
+				})
+				ctx.Abort()
+				return
+			}
+			if authenticateApiKey(ctx, m.apiKeyRepo, key, scopes) {
+				ctx.Next()
+			}
+			return
+		}
+
 		authHeader := ctx.Request.Header.Get("Authorization")
 		tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
 		if !found {
@@ -34,7 +59,7 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.VerifyAccessToken(tokenString)
+		claims, err := utils.VerifyAccessToken(m.appSecret, tokenString)
 		if err != nil {
 			message := "Invalid or expired token"
 			switch err {
@@ -73,7 +98,62 @@ func (m *AuthMiddleware) Auth() gin.HandlerFunc {
 
 		ctx.Set("userId", claims.Id)
 		ctx.Set("sessionId", claims.SessionId)
+		ctx.Set("authMethod", "token")
 
 		ctx.Next()
 	}
 }
+
+// apiKeyFromRequest reads an API key from the Authorization or X-API-Key
+// header.
+func apiKeyFromRequest(ctx *gin.Context) (string, bool) {
+	if key, found := strings.CutPrefix(ctx.GetHeader("Authorization"), "ApiKey "); found {
+		return strings.TrimSpace(key), true
+	}
+	if key := ctx.GetHeader("X-API-Key"); key != "" {
+		return strings.TrimSpace(key), true
+	}
+	return "", false
+}
+
+// authenticateApiKey checks the key and its scopes and sets the key owner on
+// the context. It writes the error response and returns false when the
+// request must not continue.
+func authenticateApiKey(ctx *gin.Context, apiKeyRepo *repository.ApiKeyRepository, key string, scopes []string) bool {
+	apiKey, err := apiKeyRepo.GetActiveByHash(ctx.Request.Context(), utils.HashToken(key))
+	if err != nil {
+		if err.Error() == "api key not found" {
+			ctx.JSON(http.StatusUnauthorized, response.ResponseError{
+				Success: false,
+				Error:   "Invalid, expired or revoked API key",
+			})
+		} else {
+			ctx.JSON(http.StatusInternalServerError, response.ResponseError{
+				Success: false,
+				Error:   "Failed to verify API key",
+			})
+		}
+		ctx.Abort()
+		return false
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(apiKey.Scopes, scope) {
+			ctx.JSON(http.StatusForbidden, response.ResponseError{
+				Success: false,
+				Error:   "API key is missing the " + scope + " scope",
+			})
+			ctx.Abort()
+			return false
+		}
+	}
+
+	if err := apiKeyRepo.TouchLastUsed(ctx.Request.Context(), apiKey.Id); err != nil {
+		log.Printf("[AUTH] failed to update last use of api key %d: %v", apiKey.Id, err)
+	}
+
+	ctx.Set("userId", apiKey.UserId)
+	ctx.Set("apiKeyId", apiKey.Id)
+	ctx.Set("authMethod", "api_key")
+	return true
+}
@@ -1,17 +1,16 @@
 package middlewares
 
 import (
-	"os"
-
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func CorsMiddleware() gin.HandlerFunc {
+func CorsMiddleware(originURL string) gin.HandlerFunc {
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{os.Getenv("ORIGIN_URL")},
-		AllowMethods:     []string{"PATCH", "POST", "GET", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Authorization"},
+		AllowOrigins:     []string{originURL},
+		AllowMethods:     []string{"PATCH", "POST", "PUT", "GET", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Content-Type", "Authorization", "X-API-Key"},
+		ExposeHeaders:    []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * 3600,
 	})
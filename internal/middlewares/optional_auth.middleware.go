@@ -10,16 +10,31 @@ import (
 
 type OptionalAuthMiddleware struct {
 	sessionRepo *repository.SessionRepository
+	apiKeyRepo  *repository.ApiKeyRepository
+	appSecret   string
 }
 
-func NewOptionalAuthMiddleware(sessionRepo *repository.SessionRepository) *OptionalAuthMiddleware {
+func NewOptionalAuthMiddleware(sessionRepo *repository.SessionRepository, apiKeyRepo *repository.ApiKeyRepository, appSecret string) *OptionalAuthMiddleware {
 	return &OptionalAuthMiddleware{
 		sessionRepo: sessionRepo,
+		apiKeyRepo:  apiKeyRepo,
+		appSecret:   appSecret,
 	}
 }
 
-func (m *OptionalAuthMiddleware) OptionalAuth() gin.HandlerFunc {
+// OptionalAuth identifies the user when a valid access token is sent and
+// lets anonymous requests through otherwise. An API key with the given
+// scopes is accepted too, but unlike a token a bad key is rejected, so a
+// script never creates anonymous links by accident.
+func (m *OptionalAuthMiddleware) OptionalAuth(scopes ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		if key, ok := apiKeyFromRequest(ctx); ok && len(scopes) > 0 {
+			if authenticateApiKey(ctx, m.apiKeyRepo, key, scopes) {
+				ctx.Next()
+			}
+			return
+		}
+
 		authHeader := ctx.Request.Header.Get("Authorization")
 
 		tokenString, found := strings.CutPrefix(authHeader, "Bearer ")
@@ -28,7 +43,7 @@ func (m *OptionalAuthMiddleware) OptionalAuth() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := utils.VerifyAccessToken(tokenString)
+		claims, err := utils.VerifyAccessToken(m.appSecret, tokenString)
 		if err != nil {
 			ctx.Next()
 			return
@@ -42,6 +57,7 @@ func (m *OptionalAuthMiddleware) OptionalAuth() gin.HandlerFunc {
 
 		ctx.Set("userId", claims.Id)
 		ctx.Set("sessionId", claims.SessionId)
+		ctx.Set("authMethod", "token")
 
 		ctx.Next()
 	}
@@ -3,26 +3,115 @@ package middlewares
 import (
 	"backend-koda-shortlink/internal/config"
 	"backend-koda-shortlink/pkg/response"
+	"log"
+	"net/http"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 )
 
-func RateLimiter(limit int, window time.Duration) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		path := c.FullPath()
+// tokenBucket takes one token from the bucket in KEYS[1], refilled with
+// ARGV[1] tokens per ARGV[2] milliseconds up to ARGV[1]. The Redis clock is
+// used so every app instance sees the same time. It returns whether the
+// request is allowed, the tokens left, the milliseconds until a token is
+// available and the milliseconds until the bucket is full again.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local rate = capacity / window
+
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local state = redis.call("HMGET", KEYS[1], "tokens", "ts")
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens), "ts", now)
+-- An untouched bucket is full again after one window, so it can go.
+redis.call("PEXPIRE", KEYS[1], window)
+
+return {allowed, math.floor(tokens), retry, math.ceil((capacity - tokens) / rate)}
+`)
+
+// RateLimiter applies token bucket policies kept in Redis.
+type RateLimiter struct {
+	failOpen bool
+	// lastErrorLog throttles logging while Redis is down.
+	lastErrorLog atomic.Int64
+}
 
-		key := "ratelimit:" + ip + ":" + path
+func NewRateLimiter(failOpen bool) *RateLimiter {
+	return &RateLimiter{failOpen: failOpen}
+}
+
+// Limit returns a middleware that applies policy under name. Registered
+// after the auth middleware it limits per API key or user, otherwise per
+// IP. Every response carries the X-RateLimit-* headers and rejected ones a
+// Retry-After.
+func (l *RateLimiter) Limit(name string, policy config.RateLimitPolicy) gin.HandlerFunc {
+	return l.limit(name, policy, rateLimitClient)
+}
+
+// LimitByIP applies policy per client IP, whoever is signed in. Registered
+// ahead of the auth middleware it also counts requests that fail to
+// authenticate.
+func (l *RateLimiter) LimitByIP(name string, policy config.RateLimitPolicy) gin.HandlerFunc {
+	return l.limit(name, policy, func(c *gin.Context) string {
+		return "ip:" + c.ClientIP()
+	})
+}
+
+func (l *RateLimiter) limit(name string, policy config.RateLimitPolicy, client func(c *gin.Context) string) gin.HandlerFunc {
+	limit := strconv.Itoa(policy.Requests)
+	window := policy.Window.Milliseconds()
+
+	return func(c *gin.Context) {
+		key := "ratelimit:" + name + ":" + client(c)
 
-		count, _ := config.Rdb.Incr(c, key).Result()
+		result, err := tokenBucket.Run(c.Request.Context(), config.Rdb, []string{key}, policy.Requests, window).Int64Slice()
+		if err != nil {
+			l.logError(err)
+			if l.failOpen {
+				c.Next()
+				return
+			}
 
-		if count == 1 {
-			config.Rdb.Expire(c, key, window)
+			c.JSON(http.StatusServiceUnavailable, response.ResponseError{
+				Success: false,
+				Error:   "Rate limiter unavailable, please try again later",
+			})
+			c.Abort()
+			return
 		}
 
-		if count > int64(limit) {
-			c.JSON(429, response.ResponseError{
+		allowed, remaining, retryAfter, reset := result[0] == 1, result[1], result[2], result[3]
+
+		c.Header("X-RateLimit-Limit", limit)
+		c.Header("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		c.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(reset), 10))
+
+		if !allowed {
+			c.Header("Retry-After", strconv.FormatInt(ceilSeconds(retryAfter), 10))
+			c.JSON(http.StatusTooManyRequests, response.ResponseError{
 				Success: false,
 				Error:   "Too many requests",
 			})
@@ -33,3 +122,32 @@ func RateLimiter(limit int, window time.Duration) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// rateLimitClient identifies who a request counts against.
+func rateLimitClient(c *gin.Context) string {
+	if apiKeyId := c.GetInt("apiKeyId"); apiKeyId != 0 {
+		return "key:" + strconv.Itoa(apiKeyId)
+	}
+	if userId := c.GetInt("userId"); userId != 0 {
+		return "user:" + strconv.Itoa(userId)
+	}
+	return "ip:" + c.ClientIP()
+}
+
+func (l *RateLimiter) logError(err error) {
+	now := time.Now().Unix()
+	last := l.lastErrorLog.Load()
+	if now-last < 60 || !l.lastErrorLog.CompareAndSwap(last, now) {
+		return
+	}
+
+	mode := "rejecting"
+	if l.failOpen {
+		mode = "allowing"
+	}
+	log.Printf("[RATELIMIT] redis error, %s requests: %v", mode, err)
+}
+
+func ceilSeconds(ms int64) int64 {
+	return (ms + 999) / 1000
+}
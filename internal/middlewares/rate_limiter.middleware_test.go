@@ -0,0 +1,138 @@
+package middlewares
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+// useRedis points config.Rdb at addr for the test. Failed commands and dials
+// aren't retried, so a stopped server fails fast.
+func useRedis(t *testing.T, addr string) {
+	t.Helper()
+
+	previous := config.Rdb
+	config.Rdb = redis.NewClient(&redis.Options{Addr: addr, MaxRetries: -1, DialerRetries: 1})
+	t.Cleanup(func() {
+		config.Rdb.Close()
+		config.Rdb = previous
+	})
+}
+
+func newLimitedRouter(l *RateLimiter, policy config.RateLimitPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/", l.LimitByIP("test", policy), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return r
+}
+
+func get(r *gin.Engine) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.1:1234"
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	useRedis(t, mr.Addr())
+	// The script reads the Redis clock, which only moves with SetTime.
+	now := time.Unix(1700000000, 0)
+	mr.SetTime(now)
+
+	// One token every 2 seconds.
+	r := newLimitedRouter(NewRateLimiter(false), config.RateLimitPolicy{Requests: 3, Window: 6 * time.Second})
+
+	for i, want := range []struct{ remaining, reset string }{{"2", "2"}, {"1", "4"}, {"0", "6"}} {
+		w := get(r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d, want 200", i+1, w.Code)
+		}
+		if got := w.Header().Get("X-RateLimit-Limit"); got != "3" {
+			t.Errorf("request %d: X-RateLimit-Limit = %s, want 3", i+1, got)
+		}
+		if got := w.Header().Get("X-RateLimit-Remaining"); got != want.remaining {
+			t.Errorf("request %d: X-RateLimit-Remaining = %s, want %s", i+1, got, want.remaining)
+		}
+		if got := w.Header().Get("X-RateLimit-Reset"); got != want.reset {
+			t.Errorf("request %d: X-RateLimit-Reset = %s, want %s", i+1, got, want.reset)
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Errorf("request %d: Retry-After on an allowed request", i+1)
+		}
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	mr.SetTime(now)
+	w := get(r)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("request past the limit: status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %s, want 2 for the 1.5s until the next token", got)
+	}
+	if got := w.Header().Get("X-RateLimit-Remaining"); got != "0" {
+		t.Errorf("X-RateLimit-Remaining = %s, want 0", got)
+	}
+
+	// A refused request takes no token, so one is ready 2s after the last.
+	now = now.Add(1500 * time.Millisecond)
+	mr.SetTime(now)
+	if w := get(r); w.Code != http.StatusOK {
+		t.Fatalf("request after the refill: status %d, want 200", w.Code)
+	}
+	if w := get(r); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request after one token refilled: status %d, want 429", w.Code)
+	}
+
+	// The bucket is full again after a whole window and doesn't overfill.
+	now = now.Add(time.Minute)
+	mr.SetTime(now)
+	if w := get(r); w.Header().Get("X-RateLimit-Remaining") != "2" {
+		t.Errorf("X-RateLimit-Remaining after a long pause = %s, want 2", w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	key := "ratelimit:test:ip:203.0.113.1"
+	if ttl := mr.TTL(key); ttl != 6*time.Second {
+		t.Errorf("bucket TTL = %s, want the 6s window", ttl)
+	}
+}
+
+func TestRateLimiterRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	useRedis(t, mr.Addr())
+	mr.Close()
+
+	policy := config.RateLimitPolicy{Requests: 1, Window: time.Minute}
+	tests := []struct {
+		name     string
+		failOpen bool
+		want     int
+	}{
+		{"fail open", true, http.StatusOK},
+		{"fail closed", false, http.StatusServiceUnavailable},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := newLimitedRouter(NewRateLimiter(tc.failOpen), policy)
+			for range 2 {
+				w := get(r)
+				if w.Code != tc.want {
+					t.Fatalf("status %d, want %d", w.Code, tc.want)
+				}
+				if w.Header().Get("X-RateLimit-Limit") != "" {
+					t.Error("X-RateLimit-Limit set without Redis")
+				}
+			}
+		})
+	}
+}
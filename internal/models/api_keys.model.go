@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+const (
+	ScopeLinksRead     = "links:read"
+	ScopeLinksWrite    = "links:write"
+	ScopeAnalyticsRead = "analytics:read"
+)
+
+type ApiKey struct {
+	Id         int        `json:"id"`
+	UserId     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type CreateApiKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=links:read links:write analytics:read" enums:"links:read,links:write,analytics:read"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+}
+
+type CreateApiKeyResponse struct {
+	ApiKey
+	// Key is the full secret. It is only returned once, at creation.
+	Key string `json:"key"`
+}
@@ -1,11 +1,14 @@
 package models
 
+import "time"
+
 type User struct {
-	Id           int     `json:"id" db:"id"`
-	ProfilePhoto *string `json:"profilePhoto" db:"profile_photo"`
-	FullName     string  `json:"fullName" db:"fullname"`
-	Email        string  `json:"email" db:"email"`
-	Password     string  `json:"-" db:"password"`
+	Id              int        `json:"id" db:"id"`
+	ProfilePhoto    *string    `json:"profilePhoto" db:"profile_photo"`
+	FullName        string     `json:"fullName" db:"fullname"`
+	Email           string     `json:"email" db:"email"`
+	Password        string     `json:"-" db:"password"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt" db:"email_verified_at"`
 }
 
 type RegisterRequest struct {
@@ -20,14 +23,33 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	AccessToken  string `json:"accessToken"`
-	RefreshToken string `json:"refreshToken"`
+	AccessToken      string    `json:"accessToken,omitempty"`
+	RefreshToken     string    `json:"refreshToken,omitempty"`
+	RefreshExpiresAt time.Time `json:"-"`
+	// TwoFactorRequired is set instead of the tokens when the password was
+	// right but the account has two-factor authentication. ChallengeToken
+	// then has to be sent with a code to /auth/2fa/verify.
+	TwoFactorRequired bool   `json:"twoFactorRequired,omitempty"`
+	ChallengeToken    string `json:"challengeToken,omitempty"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
+type VerifyEmailRequest struct {
+	Token string `form:"token" json:"token" binding:"required"`
+}
+
+type EmailRequest struct {
+	Email string `form:"email" json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `form:"token" json:"token" binding:"required"`
+	Password string `form:"password" json:"password" binding:"required,min=8"`
+}
+
 type LogoutRequest struct {
 	RefreshToken string `json:"refreshToken" binding:"required"`
 }
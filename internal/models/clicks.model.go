@@ -1,9 +1,12 @@
 package models
 
+import "time"
+
 type Click struct {
 	ID          int
 	ShortLinkID int
-	ClickedAt   string
+	ShortCode   string
+	ClickedAt   time.Time
 	IPAddress   string
 	Referer     string
 	UserAgent   string
@@ -0,0 +1,13 @@
+package models
+
+// QRCodeOptions are the query parameters of the QR code endpoint. Unset
+// fields take the defaults of the service.
+type QRCodeOptions struct {
+	Format     string `form:"format" example:"png"`
+	Size       int    `form:"size" example:"512"`
+	Margin     *int   `form:"margin" example:"4"`
+	Level      string `form:"level" example:"m"`
+	Foreground string `form:"fg" example:"000000"`
+	Background string `form:"bg" example:"ffffff"`
+	Logo       string `form:"logo" example:"https://example.com/logo.png"`
+}
@@ -3,13 +3,27 @@ package models
 import "time"
 
 type Session struct {
-	Id           int        `json:"id" db:"id"`
-	UserId       int        `json:"userId" db:"user_id"`
-	RefreshToken string     `json:"-" db:"refresh_token"`
-	LoginTime    *time.Time `json:"loginTime,omitempty" db:"-"`
-	LogoutTime   *time.Time `json:"logoutTime,omitempty" db:"-"`
-	ExpiredAt    time.Time  `json:"expiredAt" db:"expired_at"`
-	IpAddress    string     `json:"ipAddress,omitempty" db:"-"`
-	UserAgent    string     `json:"userAgent,omitempty" db:"-"`
-	IsActive     bool       `json:"isActive" db:"is_active"`
+	Id               int        `json:"id" db:"id"`
+	UserId           int        `json:"userId" db:"user_id"`
+	RefreshTokenHash string     `json:"-" db:"refresh_token_hash"`
+	LoginTime        *time.Time `json:"loginTime,omitempty" db:"-"`
+	LogoutTime       *time.Time `json:"logoutTime,omitempty" db:"-"`
+	ExpiredAt        time.Time  `json:"expiredAt" db:"expired_at"`
+	IpAddress        string     `json:"ipAddress,omitempty" db:"-"`
+	UserAgent        string     `json:"userAgent,omitempty" db:"-"`
+	IsActive         bool       `json:"isActive" db:"is_active"`
+	LastSeenAt       *time.Time `json:"lastSeenAt,omitempty" db:"-"`
+}
+
+type SessionInfo struct {
+	Id         int        `json:"id"`
+	IpAddress  string     `json:"ipAddress"`
+	UserAgent  string     `json:"userAgent"`
+	Browser    string     `json:"browser"`
+	OS         string     `json:"os"`
+	DeviceType string     `json:"deviceType"`
+	LoginTime  *time.Time `json:"loginTime"`
+	LastSeenAt *time.Time `json:"lastSeenAt"`
+	ExpiredAt  time.Time  `json:"expiredAt"`
+	Current    bool       `json:"current"`
 }
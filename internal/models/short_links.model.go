@@ -10,23 +10,110 @@ type ShortLink struct {
 	IsActive      bool       `json:"isActive" db:"is_active"`
 	ClickCount    int        `json:"clickCount" db:"click_count"`
 	LastClickedAt *time.Time `json:"lastClicked_at,omitempty" db:"last_clicked_at"`
+	ExpiredAt     *time.Time `json:"expiresAt,omitempty" db:"expired_at"`
+	MaxClicks     *int       `json:"maxClicks,omitempty" db:"max_clicks"`
+	Password      *string    `json:"-" db:"password"`
+	IsProtected   bool       `json:"isProtected" db:"-"`
 	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
 	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
 	CreatedBy     *int       `json:"createdBy,omitempty" db:"created_by"`
 	UpdatedBy     *int       `json:"updatedBy,omitempty" db:"updated_by"`
+	UTM           UTMParams  `json:"utm"`
+	// ForwardQuery passes the query string of the short URL on to the
+	// destination.
+	ForwardQuery bool `json:"forwardQuery" db:"forward_query"`
+	// ClaimToken is only set right after an anonymous link was created, the
+	// database keeps ClaimTokenHash until a registered user claims the link.
+	ClaimToken     string  `json:"-" db:"-"`
+	ClaimTokenHash *string `json:"-" db:"claim_token_hash"`
+	// ExpiresUnlessClaimed marks an expiry set because the link is
+	// anonymous, which is lifted when it gets claimed.
+	ExpiresUnlessClaimed bool `json:"-" db:"expires_unless_claimed"`
+}
+
+// UTMParams are merged into the destination as utm_* query parameters,
+// replacing ones the destination already has. Empty fields are left out.
+type UTMParams struct {
+	Source   string `json:"source,omitempty" db:"utm_source" binding:"max=200" example:"newsletter"`
+	Medium   string `json:"medium,omitempty" db:"utm_medium" binding:"max=200" example:"email"`
+	Campaign string `json:"campaign,omitempty" db:"utm_campaign" binding:"max=200" example:"spring_sale"`
+	Term     string `json:"term,omitempty" db:"utm_term" binding:"max=200"`
+	Content  string `json:"content,omitempty" db:"utm_content" binding:"max=200"`
 }
 
 type ShortLinkResponse struct {
-	ShortCode   string `json:"shortCode"`
-	OriginalUrl string `json:"originalUrl"`
-	ShortUrl    string `json:"shortUrl"`
+	ShortCode   string     `json:"shortCode"`
+	OriginalUrl string     `json:"originalUrl"`
+	ShortUrl    string     `json:"shortUrl"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	MaxClicks   *int       `json:"maxClicks,omitempty"`
+	IsProtected bool       `json:"isProtected"`
+	// ClaimToken lets the creator of an anonymous link claim it after
+	// signing up. It is only returned once.
+	ClaimToken   string     `json:"claimToken,omitempty"`
+	UTM          *UTMParams `json:"utm,omitempty"`
+	ForwardQuery bool       `json:"forwardQuery"`
 }
 
 type CreateShortLinkRequest struct {
-	OriginalURL string `json:"originalUrl" validate:"required,url"`
+	OriginalURL string     `json:"originalUrl" binding:"required,url"`
+	Alias       string     `json:"alias,omitempty" example:"promo-oct"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+	MaxClicks   *int       `json:"maxClicks,omitempty" example:"1000"`
+	Password    string     `json:"password,omitempty" example:"s3cret"`
+	UTM         *UTMParams `json:"utm,omitempty"`
+	// ForwardQuery makes /abc?ref=x redirect with ref=x added to the
+	// destination.
+	ForwardQuery bool `json:"forwardQuery,omitempty"`
+	// ChallengeResponse solves the challenge from GET /links/challenge and
+	// is required for anonymous requests unless challenges are disabled.
+	ChallengeResponse string `json:"challengeResponse,omitempty"`
 }
 
 type UpdateShortLinkRequest struct {
-	OriginalURL *string `json:"originalUrl,omitempty" validate:"omitempty,url"`
-	IsActive    *bool   `json:"isActive,omitempty"`
+	OriginalURL *string    `json:"originalUrl,omitempty" binding:"omitempty,url"`
+	IsActive    *bool      `json:"isActive,omitempty"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty" example:"2026-12-31T23:59:59Z"`
+	MaxClicks   *int       `json:"maxClicks,omitempty" example:"1000"`
+	Password    *string    `json:"password,omitempty" example:"s3cret"`
+	// UTM replaces all UTM fields, an empty object removes them.
+	UTM          *UTMParams `json:"utm,omitempty"`
+	ForwardQuery *bool      `json:"forwardQuery,omitempty"`
+	// ClearExpiresAt and ClearMaxClicks remove the expiration date and the
+	// click limit, which a missing expiresAt or maxClicks leaves unchanged.
+	ClearExpiresAt bool `json:"clearExpiresAt,omitempty"`
+	ClearMaxClicks bool `json:"clearMaxClicks,omitempty"`
+}
+
+type UnlockShortLinkRequest struct {
+	Password string `form:"password" json:"password" binding:"required"`
+}
+
+type BulkShortLinkItem struct {
+	OriginalURL string `json:"originalUrl" example:"https://example.com/campaign"`
+	Alias       string `json:"alias,omitempty" example:"promo-oct"`
+}
+
+type BulkShortLinkResult struct {
+	Row         int    `json:"row"`
+	Success     bool   `json:"success"`
+	ShortCode   string `json:"shortCode,omitempty"`
+	ShortUrl    string `json:"shortUrl,omitempty"`
+	OriginalUrl string `json:"originalUrl"`
+	Error       string `json:"error,omitempty"`
+}
+
+// AnonymousChallenge describes what an anonymous client has to solve before
+// creating a link. Type is "none", "pow" with Challenge and Difficulty, or
+// the captcha service ("turnstile", "hcaptcha", "recaptcha") with SiteKey.
+type AnonymousChallenge struct {
+	Type       string     `json:"type" example:"pow"`
+	SiteKey    string     `json:"siteKey,omitempty"`
+	Challenge  string     `json:"challenge,omitempty"`
+	Difficulty int        `json:"difficulty,omitempty" example:"20"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+}
+
+type ClaimShortLinksRequest struct {
+	ClaimTokens []string `json:"claimTokens" binding:"required,min=1,max=100,dive,required"`
 }
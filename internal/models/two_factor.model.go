@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// TwoFactor is the TOTP state of a user. Secret is set once enrollment
+// started, EnabledAt once it was confirmed with a code.
+type TwoFactor struct {
+	Secret    *string
+	EnabledAt *time.Time
+	LastStep  int64
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool       `json:"enabled"`
+	EnabledAt         *time.Time `json:"enabledAt"`
+	RecoveryCodesLeft int        `json:"recoveryCodesLeft"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauthUri"`
+	// QRCode is a PNG data URI of OtpauthURI for authenticator apps to scan.
+	QRCode string `json:"qrCode"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorEnrollRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	// Code is a code of the authenticator app or an unused recovery code.
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `form:"challengeToken" json:"challengeToken" binding:"required"`
+	// Code is a code of the authenticator app or an unused recovery code.
+	Code string `form:"code" json:"code" binding:"required"`
+}
@@ -0,0 +1,18 @@
+package models
+
+type UpdateProfileRequest struct {
+	FullName *string `json:"fullName" binding:"omitempty,max=255"`
+	Email    *string `json:"email" binding:"omitempty,email,max=255"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=8"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+	// Links decides what happens to the user's short links: "delete" removes
+	// them with their clicks, "anonymize" keeps them working without an owner.
+	Links string `json:"links" binding:"required,oneof=delete anonymize" enums:"delete,anonymize"`
+}
@@ -0,0 +1,73 @@
+package oauth
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Identity is the account of a user at an external provider.
+type Identity struct {
+	// Subject is the stable id of the account at the provider. Emails can
+	// change, so identities are matched on it.
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+// Provider runs the authorization code flow against one external provider.
+type Provider interface {
+	// AuthCodeURL is where the browser is sent to log in. nonce binds the
+	// ID token to this login and verifier is the PKCE code verifier.
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	// Exchange trades the code from the callback for the user's identity.
+	Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error)
+}
+
+// httpClient is used for all provider requests, so a hanging provider
+// can't hold a login request forever.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Open builds the configured providers. The callback of each one is
+// appURL + "api/v1/auth/oauth/<name>/callback", which has to be registered
+// as redirect URI at the provider.
+func Open(cfg config.OAuthConfig, appURL string) (map[string]Provider, error) {
+	providers := make(map[string]Provider, len(cfg.Providers))
+	for name, providerConfig := range cfg.Providers {
+		redirectURL := appURL + "api/v1/auth/oauth/" + name + "/callback"
+
+		switch providerConfig.Type {
+		case "oidc":
+			providers[name] = newOIDCProvider(providerConfig, redirectURL)
+		case "github":
+			providers[name] = newGitHubProvider(providerConfig, redirectURL)
+		default:
+			return nil, fmt.Errorf("unsupported oauth provider type %q for %s", providerConfig.Type, name)
+		}
+	}
+	return providers, nil
+}
+
+// Names returns the provider names in a stable order.
+func Names(providers map[string]Provider) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateVerifier returns a new PKCE code verifier.
+func GenerateVerifier() string {
+	return oauth2.GenerateVerifier()
+}
+
+var errNoEmail = errors.New("provider did not return an email address")
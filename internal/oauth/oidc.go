@@ -0,0 +1,130 @@
+package oauth
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCProvider logs in through an OpenID Connect provider such as Google.
+// The endpoints come from the discovery document of the issuer, which is
+// fetched on first use so an unreachable provider doesn't stop the server
+// from starting.
+type OIDCProvider struct {
+	cfg         config.OAuthProviderConfig
+	redirectURL string
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+	oauth    *oauth2.Config
+}
+
+func newOIDCProvider(cfg config.OAuthProviderConfig, redirectURL string) *OIDCProvider {
+	return &OIDCProvider{cfg: cfg, redirectURL: redirectURL}
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) (*oidc.Provider, *oauth2.Config, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return p.provider, p.oauth, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, httpClient), p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("discover %s: %w", p.cfg.Issuer, err)
+	}
+
+	endpoint := provider.Endpoint()
+	if p.cfg.AuthURL != "" {
+		endpoint.AuthURL = p.cfg.AuthURL
+	}
+	if p.cfg.TokenURL != "" {
+		endpoint.TokenURL = p.cfg.TokenURL
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	p.provider = provider
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     endpoint,
+		RedirectURL:  p.redirectURL,
+		Scopes:       scopes,
+	}
+	return p.provider, p.oauth, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	_, oauthConfig, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return oauthConfig.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	provider, oauthConfig, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = oidc.ClientContext(ctx, httpClient)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("verify id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	var claims struct {
+		Email         string `json:"email"`
+		EmailVerified *bool  `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	// Some providers only put the profile in the userinfo response.
+	if claims.Email == "" {
+		userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("userinfo: %w", err)
+		}
+		if err := userInfo.Claims(&claims); err != nil {
+			return nil, err
+		}
+	}
+	if claims.Email == "" {
+		return nil, errNoEmail
+	}
+
+	return &Identity{
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: claims.EmailVerified != nil && *claims.EmailVerified,
+		Name:          claims.Name,
+	}, nil
+}
@@ -0,0 +1,146 @@
+package oauth
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/oauth/oauthtest"
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const testRedirectURL = "http://localhost:8080/api/v1/auth/oauth/test/callback"
+
+func newTestOIDC(t *testing.T) (*OIDCProvider, *oauthtest.Server) {
+	t.Helper()
+
+	srv := oauthtest.NewServer("koda", "koda-secret")
+	t.Cleanup(srv.Close)
+
+	return newOIDCProvider(config.OAuthProviderConfig{
+		Type:         "oidc",
+		ClientID:     srv.ClientID,
+		ClientSecret: srv.ClientSecret,
+		Issuer:       srv.URL,
+	}, testRedirectURL), srv
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p, srv := newTestOIDC(t)
+	ctx := context.Background()
+
+	state, nonce, verifier := "state-1", "nonce-1", GenerateVerifier()
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	if q.Get("nonce") != nonce || q.Get("redirect_uri") != testRedirectURL {
+		t.Errorf("auth URL %s lacks the nonce or redirect URI", authURL)
+	}
+	if q.Get("code_challenge") == verifier || strings.Contains(authURL, verifier) {
+		t.Errorf("auth URL %s leaks the PKCE verifier", authURL)
+	}
+	if !strings.Contains(q.Get("scope"), "openid") {
+		t.Errorf("scope = %q, want openid", q.Get("scope"))
+	}
+
+	account := oauthtest.Account{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+	code, gotState, err := srv.Authorize(authURL, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotState != state {
+		t.Errorf("state = %q, want %q", gotState, state)
+	}
+
+	identity, err := p.Exchange(ctx, code, nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Identity{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}
+	if *identity != want {
+		t.Errorf("identity = %+v, want %+v", *identity, want)
+	}
+
+	if _, err := p.Exchange(ctx, code, nonce, verifier); err == nil {
+		t.Error("a code was redeemed twice")
+	}
+}
+
+func TestOIDCExchangeRejections(t *testing.T) {
+	account := oauthtest.Account{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true}
+
+	tests := []struct {
+		name     string
+		nonce    string
+		verifier func(verifier string) string
+		err      string
+	}{
+		{
+			name:     "other PKCE verifier",
+			nonce:    "nonce-1",
+			verifier: func(string) string { return GenerateVerifier() },
+			err:      "exchange code",
+		},
+		{
+			name:     "no PKCE verifier",
+			nonce:    "nonce-1",
+			verifier: func(string) string { return "" },
+			err:      "exchange code",
+		},
+		{
+			name:     "nonce of another login",
+			nonce:    "nonce-2",
+			verifier: func(verifier string) string { return verifier },
+			err:      "nonce mismatch",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, srv := newTestOIDC(t)
+			ctx := context.Background()
+
+			verifier := GenerateVerifier()
+			authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, _, err := srv.Authorize(authURL, account)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = p.Exchange(ctx, code, tc.nonce, tc.verifier(verifier))
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("Exchange() error = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestOIDCUnverifiedEmail(t *testing.T) {
+	p, srv := newTestOIDC(t)
+	ctx := context.Background()
+
+	verifier := GenerateVerifier()
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := srv.Authorize(authURL, oauthtest.Account{Subject: "sub-2", Email: "someone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, err := p.Exchange(ctx, code, "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.EmailVerified {
+		t.Error("email_verified=false reported as verified")
+	}
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AnalyticsRepository struct {
+	db       *pgxpool.Pool
+	cacheTTL time.Duration
+}
+
+func NewAnalyticsRepository(db *pgxpool.Pool, cacheTTL time.Duration) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db, cacheTTL: cacheTTL}
+}
+
+type BreakdownItem struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type LinkAnalytics struct {
+	From             time.Time       `json:"from"`
+	To               time.Time       `json:"to"`
+	TotalClicks      int             `json:"totalClicks"`
+	UniqueVisitors   int             `json:"uniqueVisitors"`
+	TimeSeries       []DailyVisit    `json:"timeSeries"`
+	Referrers        []BreakdownItem `json:"referrers"`
+	Browsers         []BreakdownItem `json:"browsers"`
+	OperatingSystems []BreakdownItem `json:"operatingSystems"`
+	DeviceTypes      []BreakdownItem `json:"deviceTypes"`
+	Countries        []BreakdownItem `json:"countries"`
+}
+
+// breakdownColumns maps each breakdown to the SQL expression it groups by.
+// The referrer is reduced to its host without a leading "www.".
+var breakdownColumns = map[string]string{
+	"referrer": `COALESCE(NULLIF(regexp_replace(lower(substring(referer from '^[a-zA-Z][a-zA-Z0-9+.-]*://([^/:?#]+)')), '^www\.', ''), ''), 'direct')`,
+	"browser":  `COALESCE(NULLIF(browser, ''), 'unknown')`,
+	"os":       `COALESCE(NULLIF(os, ''), 'unknown')`,
+	"device":   `COALESCE(NULLIF(device_type, ''), 'unknown')`,
+	"country":  `COALESCE(NULLIF(country, ''), 'unknown')`,
+}
+
+// linkAnalyticsVersionKey is the per link counterpart of chartVersionKey.
+func linkAnalyticsVersionKey(linkId int) string {
+	return "analytics:link:" + strconv.Itoa(linkId) + ":version"
+}
+
+// LinkAnalytics aggregates the clicks of one link between from (inclusive)
+// and to (exclusive), both at midnight UTC. Results are cached for the analytics cache TTL.
+func (r *AnalyticsRepository) LinkAnalytics(ctx context.Context, linkId int, from, to time.Time, limit int) (*LinkAnalytics, error) {
+	version, _ := config.Rdb.Get(ctx, linkAnalyticsVersionKey(linkId)).Result()
+	key := "analytics:link:" + strconv.Itoa(linkId) + ":v" + version + ":" + from.Format("20060102") + ":" + to.Format("20060102") + ":" + strconv.Itoa(limit)
+
+	if cached, err := config.Rdb.Get(ctx, key).Result(); err == nil && cached != "" {
+		var result LinkAnalytics
+		if json.Unmarshal([]byte(cached), &result) == nil {
+			return &result, nil
+		}
+	}
+
+	result := &LinkAnalytics{
+		From: from,
+		To:   to.AddDate(0, 0, -1),
+	}
+
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*), COUNT(DISTINCT ip_address)
+		FROM clicks
+		WHERE short_link_id = $1 AND clicked_at >= $2 AND clicked_at < $3`,
+		linkId, from, to,
+	).Scan(&result.TotalClicks, &result.UniqueVisitors)
+	if err != nil {
+		return nil, err
+	}
+
+	result.TimeSeries, err = r.dailySeries(ctx, linkId, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	breakdowns := []struct {
+		name   string
+		target *[]BreakdownItem
+	}{
+		{"referrer", &result.Referrers},
+		{"browser", &result.Browsers},
+		{"os", &result.OperatingSystems},
+		{"device", &result.DeviceTypes},
+		{"country", &result.Countries},
+	}
+	for _, b := range breakdowns {
+		*b.target, err = r.topBreakdown(ctx, linkId, b.name, from, to, limit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	jsonData, _ := json.Marshal(result)
+	config.Rdb.Set(ctx, key, jsonData, r.cacheTTL)
+
+	return result, nil
+}
+
+func (r *AnalyticsRepository) dailySeries(ctx context.Context, linkId int, from, to time.Time) ([]DailyVisit, error) {
+	query := `
+		SELECT d.day, COUNT(c.id)
+		FROM generate_series($2::timestamp, $3::timestamp - INTERVAL '1 day', INTERVAL '1 day') AS d(day)
+		LEFT JOIN clicks c
+			ON c.short_link_id = $1
+			AND c.clicked_at >= d.day
+			AND c.clicked_at < d.day + INTERVAL '1 day'
+		GROUP BY d.day
+		ORDER BY d.day ASC`
+
+	rows, err := r.db.Query(ctx, query, linkId, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []DailyVisit{}
+	for rows.Next() {
+		var d DailyVisit
+		if err := rows.Scan(&d.Day, &d.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, d)
+	}
+
+	return result, rows.Err()
+}
+
+func (r *AnalyticsRepository) topBreakdown(ctx context.Context, linkId int, name string, from, to time.Time, limit int) ([]BreakdownItem, error) {
+	column := breakdownColumns[name]
+	query := `
+		SELECT ` + column + ` AS value, COUNT(*) AS total
+		FROM clicks
+		WHERE short_link_id = $1 AND clicked_at >= $2 AND clicked_at < $3
+		GROUP BY value
+		ORDER BY total DESC, value ASC
+		LIMIT $4`
+
+	rows, err := r.db.Query(ctx, query, linkId, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []BreakdownItem{}
+	for rows.Next() {
+		var item BreakdownItem
+		if err := rows.Scan(&item.Value, &item.Count); err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
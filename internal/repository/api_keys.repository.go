@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ApiKeyRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewApiKeyRepository(db *pgxpool.Pool) *ApiKeyRepository {
+	return &ApiKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, expired_at, last_used_at, created_at`
+
+func scanApiKey(row pgx.Row, key *models.ApiKey) error {
+	return row.Scan(
+		&key.Id, &key.UserId, &key.Name, &key.Prefix, &key.Scopes,
+		&key.ExpiresAt, &key.LastUsedAt, &key.CreatedAt,
+	)
+}
+
+func (r *ApiKeyRepository) Create(ctx context.Context, key *models.ApiKey, keyHash string) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expired_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+
+	return r.db.QueryRow(ctx, query,
+		key.UserId, key.Name, key.Prefix, keyHash, key.Scopes, key.ExpiresAt,
+	).Scan(&key.Id, &key.CreatedAt)
+}
+
+// ListActiveByUserId returns the keys of a user that are neither revoked nor
+// expired, newest first.
+func (r *ApiKeyRepository) ListActiveByUserId(ctx context.Context, userId int) ([]models.ApiKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expired_at IS NULL OR expired_at > NOW())
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.ApiKey{}
+	for rows.Next() {
+		var key models.ApiKey
+		if err := scanApiKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *ApiKeyRepository) CountActiveByUserId(ctx context.Context, userId int) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM api_keys
+		WHERE user_id = $1 AND revoked_at IS NULL AND (expired_at IS NULL OR expired_at > NOW())`,
+		userId,
+	).Scan(&count)
+	return count, err
+}
+
+// GetActiveByHash finds a usable key by the hash of its secret.
+func (r *ApiKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (*models.ApiKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL AND (expired_at IS NULL OR expired_at > NOW())
+	`
+
+	key := &models.ApiKey{}
+	if err := scanApiKey(r.db.QueryRow(ctx, query, keyHash), key); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("api key not found")
+		}
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// TouchLastUsed records a use of the key at most once a minute, so busy
+// scripts don't turn every request into a write.
+func (r *ApiKeyRepository) TouchLastUsed(ctx context.Context, id int) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`,
+		id,
+	)
+	return err
+}
+
+func (r *ApiKeyRepository) Revoke(ctx context.Context, id, userId int) error {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE api_keys SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		id, userId,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("api key not found")
+	}
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"backend-koda-shortlink/internal/models"
 	"context"
 	"strconv"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -17,43 +19,171 @@ func NewClickRepository(db *pgxpool.Pool) *ClickRepository {
 	return &ClickRepository{db: db}
 }
 
-func (r *ClickRepository) Insert(ctx context.Context, data *models.Click) error {
-	query := `
-	INSERT INTO clicks
-	(short_link_id, ip_address, referer, user_agent, country, city, device_type, browser, os)
-	VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
-	RETURNING short_link_id`
-
-	_, err := r.db.Exec(ctx, query,
-		data.ShortLinkID,
-		data.IPAddress,
-		data.Referer,
-		data.UserAgent,
-		data.Country,
-		data.City,
-		data.DeviceType,
-		data.Browser,
-		data.OS,
-	)
+// SaveBatch stores a batch of clicks with COPY and applies the aggregated
+// click_count and last_clicked_at changes to their links in the same
+// transaction.
+func (r *ClickRepository) SaveBatch(ctx context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
 
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	// Links can be deleted while their clicks wait in the queue. Those clicks
+	// are dropped here instead of failing the foreign key check of the COPY.
+	linkIds := make([]int, 0, len(clicks))
+	for _, click := range clicks {
+		linkIds = append(linkIds, click.ShortLinkID)
+	}
+	rows, err := tx.Query(ctx, `SELECT id FROM short_links WHERE id = ANY($1) FOR KEY SHARE`, linkIds)
+	if err != nil {
+		return err
+	}
+	existing, err := pgx.CollectRows(rows, pgx.RowTo[int])
 	if err != nil {
 		return err
 	}
+	exists := make(map[int]bool, len(existing))
+	for _, id := range existing {
+		exists[id] = true
+	}
+
+	kept := clicks[:0:0]
+	for _, click := range clicks {
+		if exists[click.ShortLinkID] {
+			kept = append(kept, click)
+		}
+	}
+	clicks = kept
+	if len(clicks) == 0 {
+		return nil
+	}
 
-	var userId int
-	err = r.db.QueryRow(ctx,
-		`SELECT user_id FROM short_links WHERE id = $1`,
-		data.ShortLinkID,
-	).Scan(&userId)
+	type linkClicks struct {
+		shortCode   string
+		count       int
+		lastClicked time.Time
+	}
+	perLink := make(map[int]*linkClicks)
+	for _, click := range clicks {
+		lc, ok := perLink[click.ShortLinkID]
+		if !ok {
+			lc = &linkClicks{shortCode: click.ShortCode}
+			perLink[click.ShortLinkID] = lc
+		}
+		lc.count++
+		if click.ClickedAt.After(lc.lastClicked) {
+			lc.lastClicked = click.ClickedAt
+		}
+	}
+
+	ids := make([]int, 0, len(perLink))
+	counts := make([]int, 0, len(perLink))
+	lastClicked := make([]time.Time, 0, len(perLink))
+	for id, lc := range perLink {
+		ids = append(ids, id)
+		counts = append(counts, lc.count)
+		lastClicked = append(lastClicked, lc.lastClicked)
+	}
+
+	_, err = tx.CopyFrom(
+		ctx,
+		pgx.Identifier{"clicks"},
+		[]string{"short_link_id", "clicked_at", "ip_address", "referer", "user_agent", "country", "city", "device_type", "browser", "os"},
+		pgx.CopyFromSlice(len(clicks), func(i int) ([]any, error) {
+			c := clicks[i]
+			return []any{c.ShortLinkID, c.ClickedAt, c.IPAddress, c.Referer, c.UserAgent, c.Country, c.City, c.DeviceType, c.Browser, c.OS}, nil
+		}),
+	)
 	if err != nil {
 		return err
 	}
 
-	config.Rdb.Del(ctx,
-		"user:"+strconv.Itoa(userId)+":stats:links",
-		"user:"+strconv.Itoa(userId)+":stats:visits",
-		"analytics:"+strconv.Itoa(userId)+":7d",
+	rows, err = tx.Query(ctx, `
+		UPDATE short_links sl
+		SET click_count = sl.click_count + v.clicks,
+			last_clicked_at = GREATEST(sl.last_clicked_at, v.last_clicked_at)
+		FROM unnest($1::int[], $2::int[], $3::timestamp[]) AS v(id, clicks, last_clicked_at)
+		WHERE sl.id = v.id
+		RETURNING sl.user_id`,
+		ids, counts, lastClicked,
 	)
+	if err != nil {
+		return err
+	}
+
+	owners, err := pgx.CollectRows(rows, pgx.RowTo[*int])
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	// New clicks retire the cached stats and analytics of their links and
+	// owners.
+	pipe := config.Rdb.Pipeline()
+	for id, lc := range perLink {
+		pipe.IncrBy(ctx, "link:"+lc.shortCode+":clicks", int64(lc.count))
+		pipe.Incr(ctx, linkAnalyticsVersionKey(id))
+	}
+	for _, userId := range owners {
+		if userId == nil {
+			continue
+		}
+		pipe.Del(ctx,
+			"user:"+strconv.Itoa(*userId)+":stats:links",
+			"user:"+strconv.Itoa(*userId)+":stats:visits",
+		)
+		pipe.Incr(ctx, chartVersionKey(*userId))
+	}
+	pipe.Exec(ctx)
 
 	return nil
 }
+
+// StreamByUserID calls fn for the raw clicks on the user's links, optionally
+// narrowed to one short code and a clicked_at range, as rows are read.
+func (r *ClickRepository) StreamByUserID(ctx context.Context, userId int, shortCode string, from, to *time.Time, fn func(*models.Click) error) error {
+	query := `
+	SELECT c.id, c.short_link_id, sl.short_code, c.clicked_at,
+		COALESCE(c.ip_address, ''), COALESCE(c.referer, ''), COALESCE(c.user_agent, ''),
+		COALESCE(c.country, ''), COALESCE(c.city, ''), COALESCE(c.device_type, ''),
+		COALESCE(c.browser, ''), COALESCE(c.os, '')
+	FROM clicks c
+	JOIN short_links sl ON sl.id = c.short_link_id
+	WHERE sl.user_id = $1
+		AND ($2 = '' OR sl.short_code = $2)
+		AND ($3::timestamp IS NULL OR c.clicked_at >= $3)
+		AND ($4::timestamp IS NULL OR c.clicked_at < $4)
+	ORDER BY c.clicked_at ASC`
+
+	rows, err := r.db.Query(ctx, query, userId, shortCode, from, to)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var click models.Click
+		err := rows.Scan(
+			&click.ID, &click.ShortLinkID, &click.ShortCode, &click.ClickedAt,
+			&click.IPAddress, &click.Referer, &click.UserAgent,
+			&click.Country, &click.City, &click.DeviceType,
+			&click.Browser, &click.OS,
+		)
+		if err != nil {
+			return err
+		}
+		if err := fn(&click); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
@@ -11,11 +11,13 @@ import (
 )
 
 type DashboardRepository struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	statsTTL time.Duration
+	chartTTL time.Duration
 }
 
-func NewDashboardRepository(db *pgxpool.Pool) *DashboardRepository {
-	return &DashboardRepository{db: db}
+func NewDashboardRepository(db *pgxpool.Pool, statsTTL, chartTTL time.Duration) *DashboardRepository {
+	return &DashboardRepository{db: db, statsTTL: statsTTL, chartTTL: chartTTL}
 }
 
 type DailyVisit struct {
@@ -38,7 +40,7 @@ func (r *DashboardRepository) TotalLinks(ctx context.Context, userId int) (int,
 		return 0, err
 	}
 
-	config.Rdb.Set(ctx, key, total, 5*time.Minute)
+	config.Rdb.Set(ctx, key, total, r.statsTTL)
 
 	return total, nil
 }
@@ -62,46 +64,101 @@ func (r *DashboardRepository) TotalVisits(ctx context.Context, userId int) (int,
 		return 0, err
 	}
 
-	config.Rdb.Set(ctx, key, total, 5*time.Minute)
+	config.Rdb.Set(ctx, key, total, r.statsTTL)
 	return total, nil
 }
 
-func (r *DashboardRepository) Last7DaysChart(ctx context.Context, userId int) ([]DailyVisit, error) {
-	key := "analytics:" + strconv.Itoa(userId) + ":7d"
+type ChartRange struct {
+	From        time.Time `json:"from"`
+	To          time.Time `json:"to"`
+	Granularity string    `json:"granularity"`
+	Timezone    string    `json:"timezone"`
+}
+
+type VisitBucket struct {
+	Bucket time.Time `json:"bucket"`
+	Count  int       `json:"count"`
+}
+
+// chartVersionKey holds a counter that is part of the cache key of every
+// chart of a user. The click worker bumps it after storing new clicks, which
+// retires the cached charts of all ranges at once.
+func chartVersionKey(userId int) string {
+	return "analytics:" + strconv.Itoa(userId) + ":version"
+}
+
+// VisitsChart counts the user's clicks per hour, day, week or month between
+// rng.From (inclusive) and rng.To (exclusive). Buckets follow the calendar of
+// rng.Timezone and buckets without clicks are returned with a zero count.
+func (r *DashboardRepository) VisitsChart(ctx context.Context, userId int, rng ChartRange) ([]VisitBucket, error) {
+	version, _ := config.Rdb.Get(ctx, chartVersionKey(userId)).Result()
+	key := "analytics:" + strconv.Itoa(userId) + ":chart:v" + version + ":" + rng.Granularity + ":" + rng.Timezone + ":" +
+		strconv.FormatInt(rng.From.Unix(), 10) + ":" + strconv.FormatInt(rng.To.Unix(), 10)
 
 	if cached, err := config.Rdb.Get(ctx, key).Result(); err == nil && cached != "" {
-		var result []DailyVisit
+		var result []VisitBucket
 		if json.Unmarshal([]byte(cached), &result) == nil {
 			return result, nil
 		}
 	}
 
+	loc, err := time.LoadLocation(rng.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	// clicked_at holds UTC wall clock time, so both the range and the clicks
+	// are converted to the wall clock of the requested time zone.
 	query := `
-        SELECT DATE(clicked_at) AS day, COUNT(*) 
-        FROM clicks c
-        JOIN short_links sl ON sl.id = c.short_link_id
-        WHERE sl.user_id = $1
-        AND clicked_at >= NOW() - INTERVAL '7 days'
-        GROUP BY day
-        ORDER BY day ASC`
-
-	rows, err := r.db.Query(ctx, query, userId)
+		WITH buckets AS (
+			SELECT generate_series(
+				date_trunc($2, $3::timestamp),
+				date_trunc($2, $4::timestamp - INTERVAL '1 microsecond'),
+				('1 ' || $2)::interval
+			) AS bucket
+		),
+		counts AS (
+			SELECT date_trunc($2, (c.clicked_at AT TIME ZONE 'UTC') AT TIME ZONE $5) AS bucket, COUNT(*) AS total
+			FROM clicks c
+			JOIN short_links sl ON sl.id = c.short_link_id
+			WHERE sl.user_id = $1
+			AND c.clicked_at >= ($3::timestamp AT TIME ZONE $5) AT TIME ZONE 'UTC'
+			AND c.clicked_at < ($4::timestamp AT TIME ZONE $5) AT TIME ZONE 'UTC'
+			GROUP BY 1
+		)
+		SELECT b.bucket, COALESCE(c.total, 0)
+		FROM buckets b
+		LEFT JOIN counts c ON c.bucket = b.bucket
+		ORDER BY b.bucket ASC`
+
+	rows, err := r.db.Query(ctx, query, userId, rng.Granularity, wallClock(rng.From, loc), wallClock(rng.To, loc), rng.Timezone)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var result []DailyVisit
+	result := []VisitBucket{}
 	for rows.Next() {
-		var d DailyVisit
-		if err := rows.Scan(&d.Day, &d.Count); err != nil {
+		var b VisitBucket
+		if err := rows.Scan(&b.Bucket, &b.Count); err != nil {
 			return nil, err
 		}
-		result = append(result, d)
+		b.Bucket = time.Date(b.Bucket.Year(), b.Bucket.Month(), b.Bucket.Day(), b.Bucket.Hour(), 0, 0, 0, loc)
+		result = append(result, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
 	jsonData, _ := json.Marshal(result)
-	config.Rdb.Set(ctx, key, jsonData, 1*time.Minute)
+	config.Rdb.Set(ctx, key, jsonData, r.chartTTL)
 
 	return result, nil
 }
+
+// wallClock returns the local time of t in loc labelled as UTC, which is how
+// pgx sends a timestamp without time zone.
+func wallClock(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), local.Minute(), local.Second(), local.Nanosecond(), time.UTC)
+}
@@ -5,6 +5,7 @@ import (
 	"backend-koda-shortlink/internal/models"
 	"context"
 	"errors"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,7 +22,7 @@ func NewSessionRepository(db *pgxpool.Pool) *SessionRepository {
 func (r *SessionRepository) Create(ctx context.Context, session *models.Session) (int, error) {
 	var sessionId int
 	query := `
-		INSERT INTO sessions (user_id, refresh_token, expired_at, ip_address, user_agent)
+		INSERT INTO sessions (user_id, refresh_token_hash, expired_at, ip_address, user_agent)
 		VALUES ($1, $2, $3, $4, $5)
 		RETURNING id
 	`
@@ -30,7 +31,7 @@ func (r *SessionRepository) Create(ctx context.Context, session *models.Session)
 		ctx,
 		query,
 		session.UserId,
-		session.RefreshToken,
+		session.RefreshTokenHash,
 		session.ExpiredAt,
 		session.IpAddress,
 		session.UserAgent,
@@ -39,14 +40,143 @@ func (r *SessionRepository) Create(ctx context.Context, session *models.Session)
 	return sessionId, err
 }
 
-func (r *SessionRepository) GetByRefreshToken(ctx context.Context, refreshToken string) (*models.Session, error) {
+// Rotate swaps the current refresh token hash of an active session for
+// newHash and keeps oldHash as a rotated token of the session. Only one of
+// several concurrent rotations of the same token can succeed.
+func (r *SessionRepository) Rotate(ctx context.Context, oldHash, newHash string) (*models.Session, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
-		SELECT id, user_id, refresh_token, expired_at, is_active
-		FROM sessions
-		WHERE refresh_token = $1 AND is_active = true AND expired_at > NOW()
+		UPDATE sessions
+		SET refresh_token_hash = $2, last_seen_at = NOW(), updated_at = NOW()
+		WHERE refresh_token_hash = $1 AND is_active = true AND expired_at > NOW()
+		RETURNING id, user_id, refresh_token_hash, expired_at, is_active
+	`
+
+	rows, err := tx.Query(ctx, query, oldHash, newHash)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Session])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("session not found or expired")
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO session_refresh_tokens (token_hash, session_id)
+		VALUES ($1, $2)
+		ON CONFLICT (token_hash) DO NOTHING`,
+		oldHash, session.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// RotatePrevious rotates an active session again when oldHash is the token
+// rotated out of it last, at most grace ago. It covers a client sending two
+// refreshes at once, the loser of which presents the token the winner just
+// retired. The token current until now is kept as rotated in turn.
+func (r *SessionRepository) RotatePrevious(ctx context.Context, oldHash, newHash string, grace time.Duration) (*models.Session, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		SELECT s.id, s.user_id, s.refresh_token_hash, s.expired_at, s.is_active
+		FROM session_refresh_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+		  AND t.rotated_at > NOW() - make_interval(secs => $2)
+		  AND s.is_active = true AND s.expired_at > NOW()
+		  AND NOT EXISTS (
+		      SELECT 1 FROM session_refresh_tokens later
+		      WHERE later.session_id = t.session_id AND later.rotated_at > t.rotated_at
+		  )
+		FOR UPDATE OF s
+	`
+
+	rows, err := tx.Query(ctx, query, oldHash, grace.Seconds())
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Session])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("session not found or expired")
+		}
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sessions
+		SET refresh_token_hash = $2, last_seen_at = NOW(), updated_at = NOW()
+		WHERE id = $1`,
+		session.Id, newHash,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO session_refresh_tokens (token_hash, session_id)
+		VALUES ($1, $2)
+		ON CONFLICT (token_hash) DO NOTHING`,
+		session.RefreshTokenHash, session.Id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	session.RefreshTokenHash = newHash
+	return &session, nil
+}
+
+// PruneRotatedTokens forgets the rotated refresh tokens of the expired
+// sessions of a user. Their tokens expired with the session, so presenting
+// one fails verification before reuse detection is ever reached.
+func (r *SessionRepository) PruneRotatedTokens(ctx context.Context, userId int) error {
+	_, err := r.db.Exec(ctx, `
+		DELETE FROM session_refresh_tokens t
+		USING sessions s
+		WHERE s.id = t.session_id AND s.user_id = $1 AND s.expired_at <= NOW()`,
+		userId,
+	)
+	return err
+}
+
+// GetByRotatedToken returns the session a refresh token was rotated out of,
+// whether or not that session is still active.
+func (r *SessionRepository) GetByRotatedToken(ctx context.Context, tokenHash string) (*models.Session, error) {
+	query := `
+		SELECT s.id, s.user_id, s.refresh_token_hash, s.expired_at, s.is_active
+		FROM session_refresh_tokens t
+		JOIN sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
 	`
 
-	rows, err := database.DB.Query(ctx, query, refreshToken)
+	rows, err := r.db.Query(ctx, query, tokenHash)
 	if err != nil {
 		return nil, err
 	}
@@ -78,14 +208,14 @@ func (r *SessionRepository) CheckActive(ctx context.Context, sessionId int) (boo
 	return isActive, nil
 }
 
-func (r *SessionRepository) Invalidate(ctx context.Context, refreshToken string) error {
+func (r *SessionRepository) Invalidate(ctx context.Context, refreshTokenHash string) error {
 	query := `
 		UPDATE sessions
 		SET is_active = false, logout_time = NOW(), updated_at = NOW()
-		WHERE refresh_token = $1
+		WHERE refresh_token_hash = $1
 	`
 
-	_, err := database.DB.Exec(ctx, query, refreshToken)
+	_, err := database.DB.Exec(ctx, query, refreshTokenHash)
 	return err
 }
 
@@ -100,6 +230,71 @@ func (r *SessionRepository) InvalidateById(ctx context.Context, sessionId int) e
 	return err
 }
 
+// ListActiveByUserId returns the sessions of a user that can still be
+// refreshed, most recently used first.
+func (r *SessionRepository) ListActiveByUserId(ctx context.Context, userId int) ([]models.Session, error) {
+	query := `
+		SELECT id, user_id, COALESCE(ip_address, ''), COALESCE(user_agent, ''),
+		       login_time, last_seen_at, expired_at, is_active
+		FROM sessions
+		WHERE user_id = $1 AND is_active = true AND expired_at > NOW()
+		ORDER BY last_seen_at DESC NULLS LAST, id DESC
+	`
+
+	rows, err := r.db.Query(ctx, query, userId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		err := rows.Scan(&s.Id, &s.UserId, &s.IpAddress, &s.UserAgent, &s.LoginTime, &s.LastSeenAt, &s.ExpiredAt, &s.IsActive)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// InvalidateByIdAndUserId ends one active session, but only if it belongs to
+// userId.
+func (r *SessionRepository) InvalidateByIdAndUserId(ctx context.Context, sessionId, userId int) error {
+	query := `
+		UPDATE sessions
+		SET is_active = false, logout_time = NOW(), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND is_active = true
+	`
+
+	tag, err := r.db.Exec(ctx, query, sessionId, userId)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("session not found")
+	}
+	return nil
+}
+
+// InvalidateAllByUserIdExcept ends every active session of a user except
+// keepSessionId and returns how many were ended.
+func (r *SessionRepository) InvalidateAllByUserIdExcept(ctx context.Context, userId, keepSessionId int) (int64, error) {
+	query := `
+		UPDATE sessions
+		SET is_active = false, logout_time = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND is_active = true
+	`
+
+	tag, err := r.db.Exec(ctx, query, userId, keepSessionId)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
 func (r *SessionRepository) InvalidateAllByUserId(ctx context.Context, userId int) error {
 	query := `
 		UPDATE sessions
@@ -111,8 +306,8 @@ func (r *SessionRepository) InvalidateAllByUserId(ctx context.Context, userId in
 	return err
 }
 
-func (r *SessionRepository) UpdateCreatedByAndUpdatedBy(ctx context.Context, userId int) error {
-	query := `UPDATE sessions SET created_by = $1, updated_by = $1 WHERE id = $1`
-	_, err := database.DB.Exec(ctx, query, userId)
+func (r *SessionRepository) UpdateCreatedByAndUpdatedBy(ctx context.Context, sessionId, userId int) error {
+	query := `UPDATE sessions SET created_by = $2, updated_by = $2 WHERE id = $1`
+	_, err := database.DB.Exec(ctx, query, sessionId, userId)
 	return err
 }
@@ -10,36 +10,105 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type ShortLinkRepository struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	cacheTTL time.Duration
 }
 
-func NewShortLinkRepository(db *pgxpool.Pool) *ShortLinkRepository {
-	return &ShortLinkRepository{db: db}
+func NewShortLinkRepository(db *pgxpool.Pool, cacheTTL time.Duration) *ShortLinkRepository {
+	return &ShortLinkRepository{db: db, cacheTTL: cacheTTL}
 }
 
 func (r *ShortLinkRepository) Create(ctx context.Context, link *models.ShortLink) error {
 	query := `
 		INSERT INTO short_links 
-		(user_id, short_code, original_url, created_by, updated_by) 
-		VALUES ($1, $2, $3, $4, $5) 
+		(user_id, short_code, original_url, expired_at, max_clicks, password, created_by, updated_by,
+		claim_token_hash, expires_unless_claimed,
+		utm_source, utm_medium, utm_campaign, utm_term, utm_content, forward_query) 
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16) 
 		RETURNING id, created_at, updated_at, is_active, click_count
 	`
 
-	config.Rdb.Del(ctx, "link:"+link.ShortCode+":destination")
+	config.Rdb.Del(ctx, "link:"+link.ShortCode+":destination", "link:"+link.ShortCode+":redirects")
 
-	return r.db.QueryRow(
+	err := r.db.QueryRow(
 		ctx,
 		query,
 		link.UserID,
 		link.ShortCode,
 		link.OriginalURL,
+		link.ExpiredAt,
+		link.MaxClicks,
+		link.Password,
 		link.CreatedBy,
 		link.UpdatedBy,
+		link.ClaimTokenHash,
+		link.ExpiresUnlessClaimed,
+		link.UTM.Source,
+		link.UTM.Medium,
+		link.UTM.Campaign,
+		link.UTM.Term,
+		link.UTM.Content,
+		link.ForwardQuery,
 	).Scan(&link.ID, &link.CreatedAt, &link.UpdatedAt, &link.IsActive, &link.ClickCount)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return errors.New("short code already in use")
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Claim hands the anonymous links with the given claim token hashes over to
+// userID and lifts their anonymous expiry. Links that already expired stay
+// unclaimed. The codes of the claimed links are returned.
+func (r *ShortLinkRepository) Claim(ctx context.Context, userID int, claimTokenHashes []string) ([]string, error) {
+	query := `
+		UPDATE short_links 
+		SET user_id = $1,
+			updated_by = $1,
+			updated_at = CURRENT_TIMESTAMP,
+			expired_at = CASE WHEN expires_unless_claimed THEN NULL ELSE expired_at END,
+			expires_unless_claimed = false,
+			claim_token_hash = NULL
+		WHERE claim_token_hash = ANY($2) AND user_id IS NULL
+			AND (expired_at IS NULL OR expired_at > $3)
+		RETURNING short_code
+	`
+	rows, err := r.db.Query(ctx, query, userID, claimTokenHashes, time.Now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	codes := []string{}
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(codes) > 0 {
+		cacheKeys := make([]string, len(codes))
+		for i, code := range codes {
+			cacheKeys[i] = "link:" + code + ":destination"
+		}
+		config.Rdb.Del(ctx, cacheKeys...)
+	}
+
+	return codes, nil
 }
 
 func (r *ShortLinkRepository) GetByShortCode(ctx context.Context, shortCode string) (*models.ShortLink, error) {
@@ -52,19 +121,9 @@ func (r *ShortLinkRepository) GetByShortCode(ctx context.Context, shortCode stri
 		}
 	}
 
-	query := `
-		SELECT id, user_id, short_code, original_url, is_active, 
-			   click_count, last_clicked_at, created_at, updated_at,
-			   created_by, updated_by
-		FROM short_links 
-		WHERE short_code = $1
-	`
+	query := `SELECT ` + shortLinkColumns + ` FROM short_links WHERE short_code = $1`
 	link := &models.ShortLink{}
-	err := r.db.QueryRow(ctx, query, shortCode).Scan(
-		&link.ID, &link.UserID, &link.ShortCode, &link.OriginalURL,
-		&link.IsActive, &link.ClickCount, &link.LastClickedAt,
-		&link.CreatedAt, &link.UpdatedAt, &link.CreatedBy, &link.UpdatedBy,
-	)
+	err := scanShortLink(r.db.QueryRow(ctx, query, shortCode), link)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, errors.New("short link not found")
@@ -72,57 +131,71 @@ func (r *ShortLinkRepository) GetByShortCode(ctx context.Context, shortCode stri
 		return nil, err
 	}
 
-	jsonData, _ := json.Marshal(link)
-	config.Rdb.Set(ctx, cacheKey, jsonData, 15*time.Minute)
+	if ttl := linkCacheTTL(link, r.cacheTTL); ttl > 0 {
+		jsonData, _ := json.Marshal(link)
+		config.Rdb.Set(ctx, cacheKey, jsonData, ttl)
+	}
 
 	return link, nil
 }
 
-func (r *ShortLinkRepository) GetAllByUserIDWithFilter(ctx context.Context, userID, limit, offset int, search, status string) ([]models.ShortLink, int, error) {
-	// Build query with filters
-	baseQuery := `FROM short_links WHERE user_id = $1`
-	countQuery := `SELECT COUNT(*) ` + baseQuery
-	selectQuery := `
-		SELECT id, user_id, short_code, original_url, is_active, 
-			   click_count, last_clicked_at, created_at, updated_at,
-			   created_by, updated_by
-	` + baseQuery
-
-	args := []interface{}{userID}
-	argCount := 1
+// linkCacheTTL keeps a cached destination from outliving the link's expiry.
+func linkCacheTTL(link *models.ShortLink, ttl time.Duration) time.Duration {
+	if link.ExpiredAt != nil {
+		if untilExpiry := time.Until(*link.ExpiredAt); untilExpiry < ttl {
+			return untilExpiry
+		}
+	}
+	return ttl
+}
+
+const shortLinkColumns = `
+	id, user_id, short_code, original_url, is_active,
+	click_count, last_clicked_at, expired_at, max_clicks, password IS NOT NULL,
+	created_at, updated_at, created_by, updated_by,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, forward_query`
+
+func scanShortLink(row pgx.Row, link *models.ShortLink) error {
+	return row.Scan(
+		&link.ID, &link.UserID, &link.ShortCode, &link.OriginalURL,
+		&link.IsActive, &link.ClickCount, &link.LastClickedAt,
+		&link.ExpiredAt, &link.MaxClicks, &link.IsProtected,
+		&link.CreatedAt, &link.UpdatedAt, &link.CreatedBy, &link.UpdatedBy,
+		&link.UTM.Source, &link.UTM.Medium, &link.UTM.Campaign, &link.UTM.Term, &link.UTM.Content,
+		&link.ForwardQuery,
+	)
+}
+
+// userLinksFilter builds the WHERE clause shared by the link listing and the
+// link export.
+func userLinksFilter(userID int, search, status string) (string, []any) {
+	where := `FROM short_links WHERE user_id = $1`
+	args := []any{userID}
 
 	if search != "" {
-		argCount++
-		baseQuery += ` AND (short_code ILIKE $` + strconv.Itoa(argCount) + ` OR original_url ILIKE $` + strconv.Itoa(argCount) + `)`
 		args = append(args, "%"+search+"%")
-		countQuery = `SELECT COUNT(*) ` + baseQuery
-		selectQuery = `
-			SELECT id, user_id, short_code, original_url, is_active, 
-				   click_count, last_clicked_at, created_at, updated_at,
-				   created_by, updated_by
-		` + baseQuery
+		where += ` AND (short_code ILIKE $` + strconv.Itoa(len(args)) + ` OR original_url ILIKE $` + strconv.Itoa(len(args)) + `)`
 	}
 
 	if status == "active" || status == "inactive" {
-		argCount++
-		isActive := status == "active"
-		baseQuery += ` AND is_active = $` + strconv.Itoa(argCount)
-		args = append(args, isActive)
-		countQuery = `SELECT COUNT(*) ` + baseQuery
-		selectQuery = `
-			SELECT id, user_id, short_code, original_url, is_active, 
-				   click_count, last_clicked_at, created_at, updated_at,
-				   created_by, updated_by
-		` + baseQuery
+		args = append(args, status == "active")
+		where += ` AND is_active = $` + strconv.Itoa(len(args))
 	}
 
+	return where, args
+}
+
+func (r *ShortLinkRepository) GetAllByUserIDWithFilter(ctx context.Context, userID, limit, offset int, search, status string) ([]models.ShortLink, int, error) {
+	baseQuery, args := userLinksFilter(userID, search, status)
+
 	var total int
-	err := r.db.QueryRow(ctx, countQuery, args...).Scan(&total)
+	err := r.db.QueryRow(ctx, `SELECT COUNT(*) `+baseQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	selectQuery += ` ORDER BY created_at DESC LIMIT $` + strconv.Itoa(argCount+1) + ` OFFSET $` + strconv.Itoa(argCount+2)
+	selectQuery := `SELECT ` + shortLinkColumns + ` ` + baseQuery +
+		` ORDER BY created_at DESC LIMIT $` + strconv.Itoa(len(args)+1) + ` OFFSET $` + strconv.Itoa(len(args)+2)
 	args = append(args, limit, offset)
 
 	rows, err := r.db.Query(ctx, selectQuery, args...)
@@ -134,12 +207,7 @@ func (r *ShortLinkRepository) GetAllByUserIDWithFilter(ctx context.Context, user
 	links := []models.ShortLink{}
 	for rows.Next() {
 		var link models.ShortLink
-		err := rows.Scan(
-			&link.ID, &link.UserID, &link.ShortCode, &link.OriginalURL,
-			&link.IsActive, &link.ClickCount, &link.LastClickedAt,
-			&link.CreatedAt, &link.UpdatedAt, &link.CreatedBy, &link.UpdatedBy,
-		)
-		if err != nil {
+		if err := scanShortLink(rows, &link); err != nil {
 			return nil, 0, err
 		}
 		links = append(links, link)
@@ -148,34 +216,84 @@ func (r *ShortLinkRepository) GetAllByUserIDWithFilter(ctx context.Context, user
 	return links, total, nil
 }
 
-func (r *ShortLinkRepository) Update(ctx context.Context, shortCode string, userID int, link *models.ShortLink) error {
+// StreamByUserIDWithFilter calls fn for every link matching the filter while
+// the rows are read from the cursor, without loading them all into memory.
+func (r *ShortLinkRepository) StreamByUserIDWithFilter(ctx context.Context, userID int, search, status string, fn func(*models.ShortLink) error) error {
+	baseQuery, args := userLinksFilter(userID, search, status)
+
+	rows, err := r.db.Query(ctx, `SELECT `+shortLinkColumns+` `+baseQuery+` ORDER BY created_at DESC`, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var link models.ShortLink
+		if err := scanShortLink(rows, &link); err != nil {
+			return err
+		}
+		if err := fn(&link); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Update applies the non-nil fields of req. passwordHash follows the same
+// rule, except that an empty string removes the password. A non-nil req.UTM
+// replaces all UTM fields.
+func (r *ShortLinkRepository) Update(ctx context.Context, shortCode string, userID int, req *models.UpdateShortLinkRequest, passwordHash *string) error {
 	query := `
 		UPDATE short_links 
 		SET original_url = COALESCE($1, original_url),
 			is_active = COALESCE($2, is_active),
-			updated_by = $3,
+			expired_at = CASE WHEN $14 THEN NULL ELSE COALESCE($3, expired_at) END,
+			max_clicks = CASE WHEN $15 THEN NULL ELSE COALESCE($4, max_clicks) END,
+			password = CASE WHEN $5::text IS NULL THEN password ELSE NULLIF($5, '') END,
+			utm_source = COALESCE($8, utm_source),
+			utm_medium = COALESCE($9, utm_medium),
+			utm_campaign = COALESCE($10, utm_campaign),
+			utm_term = COALESCE($11, utm_term),
+			utm_content = COALESCE($12, utm_content),
+			forward_query = COALESCE($13, forward_query),
+			updated_by = $6,
 			updated_at = CURRENT_TIMESTAMP
-		WHERE short_code = $4 AND user_id = $5
-		RETURNING id, updated_at
+		WHERE short_code = $7 AND user_id = $6
 	`
-	err := r.db.QueryRow(
+
+	var utm [5]*string
+	if req.UTM != nil {
+		utm = [5]*string{&req.UTM.Source, &req.UTM.Medium, &req.UTM.Campaign, &req.UTM.Term, &req.UTM.Content}
+	}
+
+	result, err := r.db.Exec(
 		ctx,
 		query,
-		link.OriginalURL,
-		link.IsActive,
-		link.UpdatedBy,
-		shortCode,
+		req.OriginalURL,
+		req.IsActive,
+		req.ExpiresAt,
+		req.MaxClicks,
+		passwordHash,
 		userID,
-	).Scan(&link.ID, &link.UpdatedAt)
-
+		shortCode,
+		utm[0],
+		utm[1],
+		utm[2],
+		utm[3],
+		utm[4],
+		req.ForwardQuery,
+		req.ClearExpiresAt,
+		req.ClearMaxClicks,
+	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return errors.New("short link not found or unauthorized")
-		}
 		return err
 	}
+	if result.RowsAffected() == 0 {
+		return errors.New("short link not found or unauthorized")
+	}
 
-	config.Rdb.Del(ctx, "link:"+link.ShortCode+":destination")
+	config.Rdb.Del(ctx, "link:"+shortCode+":destination")
 
 	return nil
 }
@@ -190,7 +308,7 @@ func (r *ShortLinkRepository) Delete(ctx context.Context, shortCode string, user
 		return errors.New("short link not found or unauthorized")
 	}
 
-	config.Rdb.Del(ctx, "link:"+shortCode+":destination")
+	config.Rdb.Del(ctx, "link:"+shortCode+":destination", "link:"+shortCode+":redirects")
 
 	return nil
 }
@@ -202,19 +320,124 @@ func (r *ShortLinkRepository) CheckShortCodeExists(ctx context.Context, shortCod
 	return exists, err
 }
 
-func (r *ShortLinkRepository) IncrementClick(ctx context.Context, code string) error {
+// ExistingShortCodes returns which of the given codes are already taken.
+func (r *ShortLinkRepository) ExistingShortCodes(ctx context.Context, shortCodes []string) (map[string]bool, error) {
+	rows, err := r.db.Query(ctx, `SELECT short_code FROM short_links WHERE short_code = ANY($1)`, shortCodes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		existing[code] = true
+	}
+
+	return existing, rows.Err()
+}
+
+// CreateBatch inserts all links with a single statement. A link whose short
+// code is already taken is skipped and reported in the returned slice
+// (indexed like links) without failing the others.
+func (r *ShortLinkRepository) CreateBatch(ctx context.Context, links []*models.ShortLink) ([]error, error) {
 	query := `
-	UPDATE short_links 
-	SET click_count = click_count + 1,
-		last_clicked_at = NOW()
-	WHERE short_code = $1`
+		INSERT INTO short_links 
+		(user_id, short_code, original_url, created_by, updated_by) 
+		SELECT * FROM unnest($1::int[], $2::text[], $3::text[], $4::int[], $5::int[])
+		ON CONFLICT DO NOTHING
+		RETURNING short_code, id, created_at, updated_at, is_active, click_count
+	`
+
+	userIds := make([]*int, len(links))
+	shortCodes := make([]string, len(links))
+	originalUrls := make([]string, len(links))
+	createdBy := make([]*int, len(links))
+	updatedBy := make([]*int, len(links))
+	for i, link := range links {
+		userIds[i] = link.UserID
+		shortCodes[i] = link.ShortCode
+		originalUrls[i] = link.OriginalURL
+		createdBy[i] = link.CreatedBy
+		updatedBy[i] = link.UpdatedBy
+	}
 
-	_, err := r.db.Exec(ctx, query, code)
+	rows, err := r.db.Query(ctx, query, userIds, shortCodes, originalUrls, createdBy, updatedBy)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer rows.Close()
 
-	config.Rdb.Incr(ctx, "link:"+code+":clicks")
+	// Rows are inserted in order, so of links sharing a code the first one
+	// is the one that got it.
+	pending := make(map[string][]int, len(links))
+	for i, link := range links {
+		pending[link.ShortCode] = append(pending[link.ShortCode], i)
+	}
 
-	return nil
+	inserted := make([]bool, len(links))
+	for rows.Next() {
+		var shortCode string
+		var id, clickCount int
+		var createdAt, updatedAt time.Time
+		var isActive bool
+		if err := rows.Scan(&shortCode, &id, &createdAt, &updatedAt, &isActive, &clickCount); err != nil {
+			return nil, err
+		}
+
+		indexes := pending[shortCode]
+		if len(indexes) == 0 {
+			continue
+		}
+		i := indexes[0]
+		pending[shortCode] = indexes[1:]
+
+		link := links[i]
+		link.ID, link.CreatedAt, link.UpdatedAt, link.IsActive, link.ClickCount = id, createdAt, updatedAt, isActive, clickCount
+		inserted[i] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rowErrors := make([]error, len(links))
+	cacheKeys := make([]string, 0, len(links))
+	for i, link := range links {
+		if !inserted[i] {
+			rowErrors[i] = errors.New("short code already in use")
+			continue
+		}
+		cacheKeys = append(cacheKeys, "link:"+link.ShortCode+":destination", "link:"+link.ShortCode+":redirects")
+	}
+	if len(cacheKeys) > 0 {
+		config.Rdb.Del(ctx, cacheKeys...)
+	}
+
+	return rowErrors, nil
+}
+
+// GetPasswordHash returns the ID of a link and its password hash, which is
+// empty for a link without password. The cached link never contains it.
+func (r *ShortLinkRepository) GetPasswordHash(ctx context.Context, shortCode string) (int, string, error) {
+	query := `SELECT id, COALESCE(password, '') FROM short_links WHERE short_code = $1`
+	var id int
+	var hash string
+	err := r.db.QueryRow(ctx, query, shortCode).Scan(&id, &hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", errors.New("short link not found")
+		}
+		return 0, "", err
+	}
+	return id, hash, nil
+}
+
+func (r *ShortLinkRepository) GetClickCount(ctx context.Context, shortCode string) (int, error) {
+	query := `SELECT click_count FROM short_links WHERE short_code = $1`
+	var count int
+	err := r.db.QueryRow(ctx, query, shortCode).Scan(&count)
+	return count, err
 }
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/utils"
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TwoFactorRepository stores the TOTP secrets and recovery codes of users.
+// Secrets are encrypted with key, since anyone reading them could produce
+// valid codes.
+type TwoFactorRepository struct {
+	db  *pgxpool.Pool
+	key []byte
+}
+
+func NewTwoFactorRepository(db *pgxpool.Pool, key []byte) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db, key: key}
+}
+
+// secretContext ties an encrypted secret to the row of its user.
+func secretContext(userId int) string {
+	return "totp_secret:" + strconv.Itoa(userId)
+}
+
+func (r *TwoFactorRepository) Get(ctx context.Context, userId int) (*models.TwoFactor, error) {
+	query := `SELECT totp_secret, totp_enabled_at, totp_last_step FROM users WHERE id = $1`
+
+	twoFactor := &models.TwoFactor{}
+	var stored *string
+	err := r.db.QueryRow(ctx, query, userId).Scan(&stored, &twoFactor.EnabledAt, &twoFactor.LastStep)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	if stored != nil {
+		secret, err := r.openSecret(ctx, userId, *stored)
+		if err != nil {
+			return nil, err
+		}
+		twoFactor.Secret = &secret
+	}
+
+	return twoFactor, nil
+}
+
+// openSecret decrypts a stored secret. Secrets stored in plain text before
+// they were encrypted are encrypted on their first use.
+func (r *TwoFactorRepository) openSecret(ctx context.Context, userId int, stored string) (string, error) {
+	if utils.IsEncrypted(stored) {
+		return utils.DecryptSecret(r.key, stored, secretContext(userId))
+	}
+
+	encrypted, err := utils.EncryptSecret(r.key, stored, secretContext(userId))
+	if err != nil {
+		return "", err
+	}
+	_, err = r.db.Exec(ctx, `UPDATE users SET totp_secret = $3 WHERE id = $1 AND totp_secret = $2`, userId, stored, encrypted)
+	if err != nil {
+		return "", err
+	}
+	return stored, nil
+}
+
+func (r *TwoFactorRepository) IsEnabled(ctx context.Context, userId int) (bool, error) {
+	var enabled bool
+	err := r.db.QueryRow(ctx, `SELECT totp_enabled_at IS NOT NULL FROM users WHERE id = $1`, userId).Scan(&enabled)
+	return enabled, err
+}
+
+// SetPendingSecret starts an enrollment. It replaces the secret of an
+// earlier unconfirmed enrollment but never the one in use.
+func (r *TwoFactorRepository) SetPendingSecret(ctx context.Context, userId int, secret string) error {
+	encrypted, err := utils.EncryptSecret(r.key, secret, secretContext(userId))
+	if err != nil {
+		return err
+	}
+
+	tag, err := r.db.Exec(ctx, `
+		UPDATE users SET totp_secret = $2, totp_last_step = 0
+		WHERE id = $1 AND totp_enabled_at IS NULL`,
+		userId, encrypted,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("two-factor already enabled")
+	}
+	return nil
+}
+
+// Enable turns on the pending enrollment and stores the first recovery
+// codes.
+func (r *TwoFactorRepository) Enable(ctx context.Context, userId int, step int64, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE users SET totp_enabled_at = NOW(), totp_last_step = $2
+		WHERE id = $1 AND totp_enabled_at IS NULL AND totp_secret IS NOT NULL`,
+		userId, step,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("two-factor already enabled")
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// UseStep records a successful TOTP code. It fails when a code of the same
+// or a later step was already used, which stops replays.
+func (r *TwoFactorRepository) UseStep(ctx context.Context, userId int, step int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE users SET totp_last_step = $2
+		WHERE id = $1 AND totp_last_step < $2`,
+		userId, step,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UseRecoveryCode consumes an unused recovery code.
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		UPDATE user_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userId, codeHash,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *TwoFactorRepository) CountRecoveryCodes(ctx context.Context, userId int) (int, error) {
+	var count int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM user_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`,
+		userId,
+	).Scan(&count)
+	return count, err
+}
+
+// ReplaceRecoveryCodes invalidates all recovery codes of the user and
+// stores new ones.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (r *TwoFactorRepository) Disable(ctx context.Context, userId int) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_step = 0
+		WHERE id = $1`,
+		userId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userId int, codeHashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1`, userId); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `
+		INSERT INTO user_recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[])`,
+		userId, codeHashes,
+	)
+	return err
+}
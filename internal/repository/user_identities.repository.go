@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserIdentityRepository links users to their accounts at external login
+// providers.
+type UserIdentityRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserIdentityRepository(db *pgxpool.Pool) *UserIdentityRepository {
+	return &UserIdentityRepository{db: db}
+}
+
+// Login returns the user linked to the provider account and records the
+// login.
+func (r *UserIdentityRepository) Login(ctx context.Context, provider, subject, email string) (int, error) {
+	query := `
+		UPDATE user_identities
+		SET last_login_at = NOW(), email = $3
+		WHERE provider = $1 AND subject = $2
+		RETURNING user_id
+	`
+
+	var userId int
+	err := r.db.QueryRow(ctx, query, provider, subject, email).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("identity not found")
+		}
+		return 0, err
+	}
+
+	return userId, nil
+}
+
+// Link connects the provider account to userId. When the account was linked
+// concurrently the existing owner is returned instead.
+func (r *UserIdentityRepository) Link(ctx context.Context, userId int, provider, subject, email string) (int, error) {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject, email, last_login_at)
+		VALUES ($1, $2, $3, $4, NOW())
+		ON CONFLICT (provider, subject) DO UPDATE SET last_login_at = NOW()
+		RETURNING user_id
+	`
+
+	var linkedUserId int
+	err := r.db.QueryRow(ctx, query, userId, provider, subject, email).Scan(&linkedUserId)
+	return linkedUserId, err
+}
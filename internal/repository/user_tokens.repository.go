@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserTokenRepository stores the hashes of single use tokens sent to users
+// by email.
+type UserTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewUserTokenRepository(db *pgxpool.Pool) *UserTokenRepository {
+	return &UserTokenRepository{db: db}
+}
+
+// Create stores a new token and retires the unused ones of the same purpose,
+// so only the most recent email works.
+func (r *UserTokenRepository) Create(ctx context.Context, userId int, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userId, purpose,
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expired_at)
+		VALUES ($1, $2, $3, $4)`,
+		userId, purpose, tokenHash, expiresAt.UTC(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Consume marks a valid token as used and returns its user. A token can only
+// be consumed once.
+func (r *UserTokenRepository) Consume(ctx context.Context, purpose, tokenHash string) (int, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expired_at > NOW()
+		RETURNING user_id
+	`
+
+	var userId int
+	err := r.db.QueryRow(ctx, query, tokenHash, purpose).Scan(&userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("invalid or expired token")
+		}
+		return 0, err
+	}
+
+	return userId, nil
+}
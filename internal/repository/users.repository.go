@@ -11,15 +11,17 @@ import (
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type UserRepository struct {
-	db *pgxpool.Pool
+	db       *pgxpool.Pool
+	cacheTTL time.Duration
 }
 
-func NewUserRepository(db *pgxpool.Pool) *UserRepository {
-	return &UserRepository{db: db}
+func NewUserRepository(db *pgxpool.Pool, cacheTTL time.Duration) *UserRepository {
+	return &UserRepository{db: db, cacheTTL: cacheTTL}
 }
 
 func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
@@ -43,7 +45,7 @@ func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
 }
 
 func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
-	query := `SELECT id, profile_photo, fullname, email, password FROM users WHERE email = $1`
+	query := `SELECT id, profile_photo, fullname, email, password, email_verified_at FROM users WHERE email = $1`
 
 	rows, err := database.DB.Query(ctx, query, email)
 	if err != nil {
@@ -74,7 +76,7 @@ func (r *UserRepository) GetById(ctx context.Context, id int) (*models.User, err
 	}
 
 	query := `
-		SELECT id, profile_photo, fullname, email, password
+		SELECT id, profile_photo, fullname, email, password, email_verified_at
 		FROM users
 		WHERE id = $1
 	`
@@ -94,7 +96,7 @@ func (r *UserRepository) GetById(ctx context.Context, id int) (*models.User, err
 	}
 
 	jsonData, _ := json.Marshal(user)
-	config.Rdb.Set(ctx, cacheKey, jsonData, 15*time.Minute)
+	config.Rdb.Set(ctx, cacheKey, jsonData, r.cacheTTL)
 
 	return &user, nil
 }
@@ -115,3 +117,269 @@ func (r *UserRepository) UpdateCreatedByAndUpdatedBy(ctx context.Context, userId
 
 	return err
 }
+
+// GetPasswordHash reads the hash straight from the database, since the cached
+// profile never contains it.
+func (r *UserRepository) GetPasswordHash(ctx context.Context, id int) (string, error) {
+	var hash string
+	err := database.DB.QueryRow(ctx, `SELECT password FROM users WHERE id = $1`, id).Scan(&hash)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", errors.New("user not found")
+		}
+		return "", err
+	}
+	return hash, nil
+}
+
+// UpdateProfile updates the given fields and reports whether the email
+// address changed, which drops its verification.
+func (r *UserRepository) UpdateProfile(ctx context.Context, id int, fullName, email *string) (*models.User, bool, error) {
+	query := `
+		UPDATE users u
+		SET fullname = COALESCE($2, u.fullname),
+		    email = COALESCE($3, u.email),
+		    email_verified_at = CASE WHEN $3 <> u.email THEN NULL ELSE u.email_verified_at END,
+		    updated_by = $1,
+		    updated_at = NOW()
+		FROM (SELECT id, email FROM users WHERE id = $1 FOR UPDATE) old
+		WHERE u.id = old.id
+		RETURNING u.id, u.profile_photo, u.fullname, u.email, u.password, u.email_verified_at, u.email <> old.email
+	`
+
+	var user models.User
+	var emailChanged bool
+	err := database.DB.QueryRow(ctx, query, id, fullName, email).Scan(
+		&user.Id, &user.ProfilePhoto, &user.FullName, &user.Email, &user.Password, &user.EmailVerifiedAt, &emailChanged,
+	)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, false, errors.New("email already registered")
+		}
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, errors.New("user not found")
+		}
+		return nil, false, err
+	}
+
+	config.Rdb.Del(ctx, "user:"+strconv.Itoa(id)+":profile")
+
+	return &user, emailChanged, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	query := `UPDATE users SET password = $2, updated_by = $1, updated_at = NOW() WHERE id = $1`
+	_, err := database.DB.Exec(ctx, query, id, passwordHash)
+
+	config.Rdb.Del(ctx, "user:"+strconv.Itoa(id)+":profile")
+
+	return err
+}
+
+func (r *UserRepository) MarkEmailVerified(ctx context.Context, id int) error {
+	query := `UPDATE users SET email_verified_at = NOW(), updated_at = NOW() WHERE id = $1 AND email_verified_at IS NULL`
+	_, err := database.DB.Exec(ctx, query, id)
+
+	config.Rdb.Del(ctx, "user:"+strconv.Itoa(id)+":profile")
+
+	return err
+}
+
+// ResetPassword consumes a password reset token and, in the same
+// transaction, sets the new password, marks the email verified, logs out
+// every session and revokes every API key. It returns the user the token
+// belonged to.
+func (r *UserRepository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (int, error) {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id int
+	err = tx.QueryRow(ctx, `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expired_at > NOW()
+		RETURNING user_id`,
+		tokenHash, TokenPurposeResetPassword,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, errors.New("invalid or expired token")
+		}
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE users
+		SET password = $2,
+		    email_verified_at = COALESCE(email_verified_at, NOW()),
+		    updated_by = $1,
+		    updated_at = NOW()
+		WHERE id = $1`,
+		id, passwordHash,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sessions
+		SET is_active = false, logout_time = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND is_active = true`,
+		id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := revokeApiKeys(ctx, tx, id); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	config.Rdb.Del(ctx, "user:"+strconv.Itoa(id)+":profile")
+
+	return id, nil
+}
+
+// ChangePassword sets a new password, logs out every session but
+// keepSessionId and revokes every API key in one transaction.
+func (r *UserRepository) ChangePassword(ctx context.Context, id int, passwordHash string, keepSessionId int) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE users SET password = $2, updated_by = $1, updated_at = NOW() WHERE id = $1`, id, passwordHash)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE sessions
+		SET is_active = false, logout_time = NOW(), updated_at = NOW()
+		WHERE user_id = $1 AND id <> $2 AND is_active = true`,
+		id, keepSessionId,
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := revokeApiKeys(ctx, tx, id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	config.Rdb.Del(ctx, "user:"+strconv.Itoa(id)+":profile")
+
+	return nil
+}
+
+// revokeApiKeys revokes the keys of a user whose password changed, since
+// whoever knew the old one could have created keys with it.
+func revokeApiKeys(ctx context.Context, tx pgx.Tx, userId int) error {
+	_, err := tx.Exec(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userId)
+	return err
+}
+
+// UpdateProfilePhoto stores photoURL and returns the URL it replaced.
+func (r *UserRepository) UpdateProfilePhoto(ctx context.Context, id int, photoURL string) (*string, error) {
+	query := `
+		UPDATE users u
+		SET profile_photo = $2, updated_by = $1, updated_at = NOW()
+		FROM (SELECT id, profile_photo FROM users WHERE id = $1 FOR UPDATE) old
+		WHERE u.id = old.id
+		RETURNING old.profile_photo
+	`
+
+	var previous *string
+	err := database.DB.QueryRow(ctx, query, id, photoURL).Scan(&previous)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+
+	config.Rdb.Del(ctx, "user:"+strconv.Itoa(id)+":profile")
+
+	return previous, nil
+}
+
+// Delete removes a user together with their sessions. With anonymizeLinks
+// the short links are detached and keep redirecting, otherwise they are
+// removed through the ON DELETE CASCADE of short_links.user_id.
+func (r *UserRepository) Delete(ctx context.Context, id int, anonymizeLinks bool) error {
+	tx, err := database.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT id, short_code FROM short_links WHERE user_id = $1`, id)
+	if err != nil {
+		return err
+	}
+	var linkIds []int
+	var shortCodes []string
+	for rows.Next() {
+		var linkId int
+		var shortCode string
+		if err := rows.Scan(&linkId, &shortCode); err != nil {
+			rows.Close()
+			return err
+		}
+		linkIds = append(linkIds, linkId)
+		shortCodes = append(shortCodes, shortCode)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if anonymizeLinks {
+		_, err = tx.Exec(ctx, `UPDATE short_links SET user_id = NULL, updated_at = NOW() WHERE user_id = $1`, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.New("user not found")
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	cacheKeys := []string{
+		"user:" + strconv.Itoa(id) + ":profile",
+		"user:" + strconv.Itoa(id) + ":stats:links",
+		"user:" + strconv.Itoa(id) + ":stats:visits",
+		chartVersionKey(id),
+	}
+	for i, code := range shortCodes {
+		cacheKeys = append(cacheKeys, "link:"+code+":destination")
+		// Anonymized links keep redirecting and keep their click budget and
+		// analytics.
+		if !anonymizeLinks {
+			cacheKeys = append(cacheKeys, "link:"+code+":redirects", linkAnalyticsVersionKey(linkIds[i]))
+		}
+	}
+	config.Rdb.Del(ctx, cacheKeys...)
+
+	return nil
+}
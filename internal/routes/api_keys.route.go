@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"backend-koda-shortlink/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func apiKeyRouter(r *gin.RouterGroup, apiKeyHandler *handlers.ApiKeyHandler) {
+	r.GET("", apiKeyHandler.ListApiKeys)
+	r.POST("", apiKeyHandler.CreateApiKey)
+	r.DELETE("/:id", apiKeyHandler.RevokeApiKey)
+}
@@ -11,4 +11,12 @@ func authRouter(r *gin.RouterGroup, authHandler *handlers.AuthHandler) {
 	r.POST("/login", authHandler.Login)
 	r.POST("/refresh", authHandler.RefreshToken)
 	r.POST("/logout", authHandler.Logout)
+	r.POST("/2fa/verify", authHandler.VerifyTwoFactor)
+	r.POST("/verify-email", authHandler.VerifyEmail)
+	r.POST("/resend-verification", authHandler.ResendVerification)
+	r.POST("/forgot-password", authHandler.ForgotPassword)
+	r.POST("/reset-password", authHandler.ResetPassword)
+	r.GET("/oauth", authHandler.OAuthProviders)
+	r.GET("/oauth/:provider", authHandler.OAuthLogin)
+	r.GET("/oauth/:provider/callback", authHandler.OAuthCallback)
 }
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"backend-koda-shortlink/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func exportRouter(r *gin.RouterGroup, exportHandler *handlers.ExportHandler) {
+	r.GET("/links", exportHandler.ExportLinks)
+	r.GET("/clicks", exportHandler.ExportClicks)
+}
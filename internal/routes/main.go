@@ -1,42 +1,146 @@
 package routes
 
 import (
+	"backend-koda-shortlink/internal/challenge"
+	"backend-koda-shortlink/internal/config"
 	"backend-koda-shortlink/internal/database"
+	"backend-koda-shortlink/internal/geoip"
 	"backend-koda-shortlink/internal/handlers"
+	"backend-koda-shortlink/internal/mailer"
 	"backend-koda-shortlink/internal/middlewares"
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/oauth"
 	"backend-koda-shortlink/internal/repository"
 	"backend-koda-shortlink/internal/services"
+	"backend-koda-shortlink/internal/storage"
+	"backend-koda-shortlink/internal/urlpolicy"
+	"backend-koda-shortlink/internal/utils"
+	"context"
+	"log"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetUpRoutes(r *gin.Engine) {
-	userRepo := repository.NewUserRepository(database.DB)
+// SetUpRoutes wires the repositories, services and handlers and registers the
+// routes. The returned function stops the background workers started here and
+// must be called after the HTTP server stopped serving requests.
+func SetUpRoutes(r *gin.Engine, cfg *config.Config) func(ctx context.Context) {
+	userRepo := repository.NewUserRepository(database.DB, cfg.Cache.ProfileTTL)
 	sessionRepo := repository.NewSessionRepository(database.DB)
-	shortLinkRepo := repository.NewShortLinkRepository(database.DB)
+	userTokenRepo := repository.NewUserTokenRepository(database.DB)
+	apiKeyRepo := repository.NewApiKeyRepository(database.DB)
+	identityRepo := repository.NewUserIdentityRepository(database.DB)
+	twoFactorRepo := repository.NewTwoFactorRepository(database.DB, utils.DeriveKey(cfg.Auth.TOTPEncryptionKey, "totp-secret"))
+	shortLinkRepo := repository.NewShortLinkRepository(database.DB, cfg.Cache.LinkTTL)
 	clickRepo := repository.NewClickRepository(database.DB)
-	dashboardRepo := repository.NewDashboardRepository(database.DB)
+	dashboardRepo := repository.NewDashboardRepository(database.DB, cfg.Cache.StatsTTL, cfg.Cache.AnalyticsTTL)
+	analyticsRepo := repository.NewAnalyticsRepository(database.DB, cfg.Cache.AnalyticsTTL)
 
-	userService := services.NewUserService(userRepo)
-	authService := services.NewAuthService(userRepo, sessionRepo)
-	shortLinkService := services.NewShortLinkService(shortLinkRepo, clickRepo)
+	geoLocator, err := geoip.Open(cfg.GeoIP.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to open GeoIP database: %v", err)
+	}
+
+	store, err := storage.Open(cfg.Storage, cfg.Server.AppURL)
+	if err != nil {
+		log.Fatalf("Failed to open file storage: %v", err)
+	}
+	if local, ok := store.(*storage.LocalStorage); ok {
+		r.Static(storage.LocalRoute, local.Dir)
+	}
+
+	mail, err := mailer.Open(cfg.Mail)
+	if err != nil {
+		log.Fatalf("Failed to set up mailer: %v", err)
+	}
+
+	oauthProviders, err := oauth.Open(cfg.OAuth, cfg.Server.AppURL)
+	if err != nil {
+		log.Fatalf("Failed to set up login providers: %v", err)
+	}
+
+	verifier, err := challenge.Open(cfg.AnonymousLinks.Challenge)
+	if err != nil {
+		log.Fatalf("Failed to set up link creation challenge: %v", err)
+	}
+
+	urlPolicy, err := urlpolicy.Open(cfg.URLPolicy, cfg.Server.AppURL)
+	if err != nil {
+		log.Fatalf("Failed to set up URL policy: %v", err)
+	}
+
+	authService := services.NewAuthService(
+		userRepo, sessionRepo, userTokenRepo, identityRepo, twoFactorRepo,
+		mail, oauthProviders, cfg.Auth, cfg.OAuth.StateTTL, cfg.Server.FrontendURL,
+	)
+	userService := services.NewUserService(userRepo, sessionRepo, store, authService)
+	sessionService := services.NewSessionService(sessionRepo)
+	apiKeyService := services.NewApiKeyService(apiKeyRepo)
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo, userRepo, sessionRepo)
+	clickService := services.NewClickService(clickRepo, geoLocator, cfg.Clicks)
+	shortLinkService := services.NewShortLinkService(shortLinkRepo, clickService, verifier, urlPolicy, cfg.AnonymousLinks, cfg.Auth.AppSecret)
 	dashboardService := services.NewDashboardService(dashboardRepo)
+	exportService := services.NewExportService(shortLinkRepo, clickRepo, cfg.Server.AppURL)
+	analyticsService := services.NewAnalyticsService(analyticsRepo, shortLinkRepo)
+	qrCodeService := services.NewQRCodeService(shortLinkRepo, cfg.Server.AppURL, cfg.Cache.QRCodeTTL)
 
 	userHandler := handlers.NewUserHandler(userService)
-	authHandler := handlers.NewAuthHandler(authService)
-	shortLinkHandler := handlers.NewShortLinkHandler(shortLinkService)
+	authHandler := handlers.NewAuthHandler(authService, cfg.Server.FrontendURL)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	apiKeyHandler := handlers.NewApiKeyHandler(apiKeyService)
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService)
+	shortLinkHandler := handlers.NewShortLinkHandler(shortLinkService, cfg.Server.AppURL)
 	dashboardHandler := handlers.NewDashboardHandler(dashboardService)
+	exportHandler := handlers.NewExportHandler(exportService)
+	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService)
+	qrCodeHandler := handlers.NewQRCodeHandler(qrCodeService)
+
+	authMiddleware := middlewares.NewAuthMiddleware(sessionRepo, apiKeyRepo, cfg.Auth.AppSecret)
+	optionalAuth := middlewares.NewOptionalAuthMiddleware(sessionRepo, apiKeyRepo, cfg.Auth.AppSecret)
+
+	// Rate limits run after authentication, so signed in clients get a
+	// bucket of their own instead of sharing the one of their IP.
+	limiter := middlewares.NewRateLimiter(cfg.RateLimit.FailOpen)
+	defaultLimit := limiter.Limit("default", cfg.RateLimit.Default())
+	authLimit := limiter.Limit("auth", cfg.RateLimit.Auth)
+	createLimit := limiter.Limit("link-create", cfg.RateLimit.LinkCreate)
+	redirectLimit := limiter.Limit("redirect", cfg.RateLimit.Redirect)
+
+	// The per IP bucket runs before any authentication, so guessed bearer
+	// tokens and API keys, each costing a lookup, are throttled as well.
+	r.Use(limiter.LimitByIP("ip", cfg.RateLimit.IP))
+
+	authRouter(r.Group("/api/v1/auth", authLimit), authHandler)
+	shortLinkRoutes(r.Group("/api/v1/links"), shortLinkHandler, authMiddleware, defaultLimit, createLimit)
+	userRouter(r.Group("/api/v1/users", authMiddleware.Auth(), defaultLimit), userHandler)
+	twoFactorRouter(r.Group("/api/v1/users/2fa", authMiddleware.Auth(), defaultLimit), twoFactorHandler)
+	sessionRouter(r.Group("/api/v1/sessions", authMiddleware.Auth(), defaultLimit), sessionHandler)
+	exportRouter(r.Group("/api/v1/exports", authMiddleware.Auth(), defaultLimit), exportHandler)
+	apiKeyRouter(r.Group("/api/v1/api-keys", authMiddleware.Auth(), defaultLimit), apiKeyHandler)
+
+	r.POST("/api/v1/links", optionalAuth.OptionalAuth(models.ScopeLinksWrite), createLimit, shortLinkHandler.CreateShortLink)
 
-	authMiddleware := middlewares.NewAuthMiddleware(sessionRepo)
-	optionalAuth := middlewares.NewOptionalAuthMiddleware(sessionRepo)
+	r.GET("/:shortCode", redirectLimit, shortLinkHandler.Redirect)
+	// Unlocking is password guessing, so it shares the auth bucket.
+	r.POST("/:shortCode/unlock", authLimit, shortLinkHandler.UnlockShortLink)
 
-	authRouter(r.Group("/api/v1/auth"), authHandler)
-	shortLinkRoutes(r.Group("/api/v1/links", authMiddleware.Auth()), shortLinkHandler)
-	userRouter(r.Group("/api/v1/users", authMiddleware.Auth()), userHandler)
+	r.GET("/api/v1/dashboard/stats", authMiddleware.Auth(models.ScopeAnalyticsRead), defaultLimit, dashboardHandler.Stats)
+	r.GET("/api/v1/links/:shortCode/analytics", authMiddleware.Auth(models.ScopeAnalyticsRead), defaultLimit, analyticsHandler.LinkAnalytics)
+	r.GET("/api/v1/links/:shortCode/qr", authMiddleware.Auth(models.ScopeLinksRead), defaultLimit, qrCodeHandler.QRCode)
 
-	r.POST("/api/v1/links", optionalAuth.OptionalAuth(), shortLinkHandler.CreateShortLink)
+	return func(ctx context.Context) {
+		if err := clickService.Shutdown(ctx); err != nil {
+			log.Printf("Click queue not fully drained: %v", err)
+		} else {
+			log.Println("Click queue drained")
+		}
 
-	r.GET("/:shortCode", shortLinkHandler.Redirect)
+		if err := authService.Shutdown(ctx); err != nil {
+			log.Printf("Pending emails not sent: %v", err)
+		}
 
-	r.GET("/api/v1/dashboard/stats", authMiddleware.Auth(), dashboardHandler.Stats)
+		if err := geoLocator.Close(); err != nil {
+			log.Printf("Failed to close GeoIP database: %v", err)
+		}
+	}
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"backend-koda-shortlink/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func sessionRouter(r *gin.RouterGroup, sessionHandler *handlers.SessionHandler) {
+	r.GET("", sessionHandler.ListSessions)
+	r.DELETE("", sessionHandler.RevokeOtherSessions)
+	r.DELETE("/:id", sessionHandler.RevokeSession)
+}
@@ -2,13 +2,21 @@ package routes
 
 import (
 	"backend-koda-shortlink/internal/handlers"
+	"backend-koda-shortlink/internal/middlewares"
+	"backend-koda-shortlink/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func shortLinkRoutes(r *gin.RouterGroup, handler *handlers.ShortLinkHandler) {
-	r.GET("", handler.GetAllLinks)
-	r.GET("/:shortCode", handler.GetLinkByShortCode)
-	r.PUT("/:shortCode", handler.UpdateShortLink)
-	r.DELETE("/:shortCode", handler.DeleteShortLink)
+func shortLinkRoutes(r *gin.RouterGroup, handler *handlers.ShortLinkHandler, auth *middlewares.AuthMiddleware, limit, createLimit gin.HandlerFunc) {
+	read := auth.Auth(models.ScopeLinksRead)
+	write := auth.Auth(models.ScopeLinksWrite)
+
+	r.GET("", read, limit, handler.GetAllLinks)
+	r.GET("/challenge", limit, handler.AnonymousChallenge)
+	r.POST("/bulk", write, createLimit, handler.CreateShortLinksBulk)
+	r.POST("/claim", write, limit, handler.ClaimShortLinks)
+	r.GET("/:shortCode", read, limit, handler.GetLinkByShortCode)
+	r.PUT("/:shortCode", write, limit, handler.UpdateShortLink)
+	r.DELETE("/:shortCode", write, limit, handler.DeleteShortLink)
 }
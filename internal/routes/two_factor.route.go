@@ -0,0 +1,15 @@
+package routes
+
+import (
+	"backend-koda-shortlink/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+func twoFactorRouter(r *gin.RouterGroup, twoFactorHandler *handlers.TwoFactorHandler) {
+	r.GET("", twoFactorHandler.Status)
+	r.POST("/enroll", twoFactorHandler.Enroll)
+	r.POST("/confirm", twoFactorHandler.Confirm)
+	r.POST("/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+	r.DELETE("", twoFactorHandler.Disable)
+}
@@ -8,4 +8,8 @@ import (
 
 func userRouter(r *gin.RouterGroup, userHandler *handlers.UserHandler) {
 	r.GET("", userHandler.GetUserDetail)
+	r.PATCH("", userHandler.UpdateProfile)
+	r.PATCH("/password", userHandler.ChangePassword)
+	r.PUT("/photo", userHandler.UploadProfilePhoto)
+	r.DELETE("", userHandler.DeleteAccount)
 }
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/repository"
+	"context"
+	"errors"
+	"time"
+)
+
+type AnalyticsService struct {
+	analyticsRepo *repository.AnalyticsRepository
+	shortLinkRepo *repository.ShortLinkRepository
+}
+
+func NewAnalyticsService(analyticsRepo *repository.AnalyticsRepository, shortLinkRepo *repository.ShortLinkRepository) *AnalyticsService {
+	return &AnalyticsService{
+		analyticsRepo: analyticsRepo,
+		shortLinkRepo: shortLinkRepo,
+	}
+}
+
+const maxAnalyticsRangeDays = 366
+
+// LinkAnalytics returns the analytics of a link owned by userId for the days
+// from through to, inclusive.
+func (s *AnalyticsService) LinkAnalytics(ctx context.Context, userId int, shortCode string, from, to time.Time, limit int) (*repository.LinkAnalytics, error) {
+	if to.Before(from) {
+		return nil, errors.New("invalid date range")
+	}
+	end := to.AddDate(0, 0, 1)
+	if end.Sub(from) > maxAnalyticsRangeDays*24*time.Hour {
+		return nil, errors.New("date range too large")
+	}
+
+	link, err := s.shortLinkRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, err
+	}
+	if link.UserID == nil || *link.UserID != userId {
+		return nil, errors.New("unauthorized access")
+	}
+
+	return s.analyticsRepo.LinkAnalytics(ctx, link.ID, from, end, limit)
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/utils"
+	"context"
+	"crypto/rand"
+	"errors"
+	"slices"
+	"time"
+)
+
+const (
+	// apiKeyPrefix marks the keys of this service so leaked ones are easy
+	// to spot by secret scanners.
+	apiKeyPrefix = "ksk_"
+	// apiKeyDisplayLen is how much of a key is kept in clear text to tell
+	// keys apart in the list.
+	apiKeyDisplayLen  = 12
+	maxApiKeysPerUser = 25
+)
+
+type ApiKeyService struct {
+	apiKeyRepo *repository.ApiKeyRepository
+}
+
+func NewApiKeyService(apiKeyRepo *repository.ApiKeyRepository) *ApiKeyService {
+	return &ApiKeyService{
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+func (s *ApiKeyService) ListApiKeys(ctx context.Context, userId int) ([]models.ApiKey, error) {
+	return s.apiKeyRepo.ListActiveByUserId(ctx, userId)
+}
+
+// CreateApiKey generates a new key. Only its hash is stored, the returned
+// key is the only time the secret can be seen.
+func (s *ApiKeyService) CreateApiKey(ctx context.Context, userId int, req *models.CreateApiKeyRequest) (*models.CreateApiKeyResponse, error) {
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return nil, errors.New("expiry must be in the future")
+	}
+
+	count, err := s.apiKeyRepo.CountActiveByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxApiKeysPerUser {
+		return nil, errors.New("api key limit reached")
+	}
+
+	scopes := slices.Clone(req.Scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	key := apiKeyPrefix + rand.Text()
+	apiKey := models.ApiKey{
+		UserId:    userId,
+		Name:      req.Name,
+		Prefix:    key[:apiKeyDisplayLen],
+		Scopes:    scopes,
+		ExpiresAt: toUTC(req.ExpiresAt),
+	}
+
+	if err := s.apiKeyRepo.Create(ctx, &apiKey, utils.HashToken(key)); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateApiKeyResponse{
+		ApiKey: apiKey,
+		Key:    key,
+	}, nil
+}
+
+func (s *ApiKeyService) RevokeApiKey(ctx context.Context, userId, id int) error {
+	return s.apiKeyRepo.Revoke(ctx, id, userId)
+}
@@ -1,24 +1,66 @@
 package services
 
 import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/mailer"
 	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/oauth"
 	"backend-koda-shortlink/internal/repository"
 	"backend-koda-shortlink/internal/utils"
 	"context"
+	"crypto/rand"
+	"encoding/json"
 	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/matthewhartstonge/argon2"
 )
 
 type AuthService struct {
-	userRepo    *repository.UserRepository
-	sessionRepo *repository.SessionRepository
+	userRepo       *repository.UserRepository
+	sessionRepo    *repository.SessionRepository
+	userTokenRepo  *repository.UserTokenRepository
+	identityRepo   *repository.UserIdentityRepository
+	twoFactorRepo  *repository.TwoFactorRepository
+	mailer         mailer.Mailer
+	oauthProviders map[string]oauth.Provider
+	loginGuard     *LoginGuard
+	authConfig     config.AuthConfig
+	oauthStateTTL  time.Duration
+	frontendURL    string
+
+	// mails tracks the emails sent in the background.
+	mails sync.WaitGroup
 }
 
-func NewAuthService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository) *AuthService {
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+	userTokenRepo *repository.UserTokenRepository,
+	identityRepo *repository.UserIdentityRepository,
+	twoFactorRepo *repository.TwoFactorRepository,
+	mailer mailer.Mailer,
+	oauthProviders map[string]oauth.Provider,
+	authConfig config.AuthConfig,
+	oauthStateTTL time.Duration,
+	frontendURL string,
+) *AuthService {
 	return &AuthService{
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		userTokenRepo:  userTokenRepo,
+		identityRepo:   identityRepo,
+		twoFactorRepo:  twoFactorRepo,
+		mailer:         mailer,
+		oauthProviders: oauthProviders,
+		loginGuard:     NewLoginGuard(authConfig.LoginProtection),
+		authConfig:     authConfig,
+		oauthStateTTL:  oauthStateTTL,
+		frontendURL:    frontendURL,
 	}
 }
 
@@ -52,12 +94,25 @@ func (s *AuthService) Register(ctx context.Context, req *models.RegisterRequest)
 		return nil, errors.New("failed to update user metadata")
 	}
 
+	// The account exists either way, a failed email can be sent again
+	// through ResendVerification.
+	if err := s.SendVerificationEmail(ctx, user); err != nil {
+		log.Printf("[MAIL] failed to send verification email to user %d: %v", user.Id, err)
+	}
+
 	return user, nil
 }
 
 func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	if err := s.loginGuard.Check(ctx, req.Email, ipAddress); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(ctx, req.Email)
 	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, s.loginFailed(ctx, req.Email, ipAddress)
+		}
 		return nil, err
 	}
 
@@ -66,20 +121,88 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ipAdd
 		[]byte(user.Password),
 	)
 	if err != nil || !isPasswordValid {
-		return nil, errors.New("wrong email or password")
+		return nil, s.loginFailed(ctx, req.Email, ipAddress)
+	}
+	s.loginGuard.RecordSuccess(ctx, req.Email, ipAddress)
+
+	if s.authConfig.RequireEmailVerification && user.EmailVerifiedAt == nil {
+		return nil, errors.New("email not verified")
+	}
+
+	return s.completeLogin(ctx, user.Id, ipAddress, userAgent)
+}
+
+// loginFailed records a wrong email or password and returns the error for
+// the caller, which reports a lockout this failure caused.
+func (s *AuthService) loginFailed(ctx context.Context, email, ipAddress string) error {
+	if err := s.loginGuard.RecordFailure(ctx, email, ipAddress); err != nil {
+		return err
+	}
+	return errors.New("wrong email or password")
+}
+
+// twoFactorChallengeTTL is how long a user has to enter the code after the
+// first login step.
+const twoFactorChallengeTTL = 5 * time.Minute
+
+// completeLogin starts a session, or returns a challenge when the account
+// also needs a two-factor code.
+func (s *AuthService) completeLogin(ctx context.Context, userId int, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	enabled, err := s.twoFactorRepo.IsEnabled(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return s.createSession(ctx, userId, ipAddress, userAgent)
+	}
+
+	challenge := rand.Text()
+	err = config.Rdb.Set(ctx, "2fa:challenge:"+utils.HashToken(challenge), userId, twoFactorChallengeTTL).Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.LoginResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    challenge,
+	}, nil
+}
+
+// VerifyTwoFactorLogin finishes a login of an account with two-factor
+// authentication.
+func (s *AuthService) VerifyTwoFactorLogin(ctx context.Context, req *models.TwoFactorLoginRequest, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	key := "2fa:challenge:" + utils.HashToken(req.ChallengeToken)
+	userId, err := config.Rdb.Get(ctx, key).Int()
+	if err != nil {
+		return nil, errors.New("invalid or expired challenge")
 	}
 
-	refreshToken, expiresAt, err := utils.GenerateRefreshToken(user.Id)
+	if err := verifySecondFactor(ctx, s.twoFactorRepo, userId, req.Code); err != nil {
+		return nil, err
+	}
+
+	// A challenge starts one session only.
+	if deleted, err := config.Rdb.Del(ctx, key).Result(); err != nil || deleted == 0 {
+		return nil, errors.New("invalid or expired challenge")
+	}
+
+	return s.createSession(ctx, userId, ipAddress, userAgent)
+}
+
+// createSession starts a session for a user who proved who they are and
+// issues its access and refresh token.
+func (s *AuthService) createSession(ctx context.Context, userId int, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	refreshToken, expiresAt, err := utils.GenerateRefreshToken(s.authConfig.RefreshSecret, s.authConfig.RefreshTokenTTL, userId)
 	if err != nil {
 		return nil, errors.New("failed to generate refresh token")
 	}
 
 	session := &models.Session{
-		UserId:       user.Id,
-		RefreshToken: refreshToken,
-		ExpiredAt:    expiresAt,
-		IpAddress:    ipAddress,
-		UserAgent:    userAgent,
+		UserId:           userId,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		ExpiredAt:        expiresAt,
+		IpAddress:        ipAddress,
+		UserAgent:        userAgent,
 	}
 
 	sessionId, err := s.sessionRepo.Create(ctx, session)
@@ -87,41 +210,402 @@ func (s *AuthService) Login(ctx context.Context, req *models.LoginRequest, ipAdd
 		return nil, errors.New("failed to create session")
 	}
 
-	err = s.sessionRepo.UpdateCreatedByAndUpdatedBy(ctx, user.Id)
+	err = s.sessionRepo.UpdateCreatedByAndUpdatedBy(ctx, sessionId, userId)
 	if err != nil {
 		return nil, errors.New("failed to update user metadata")
 	}
 
-	accessToken, err := utils.GenerateAccessToken(user.Id, sessionId)
+	// Every rotation keeps the retired token around, so clean up after the
+	// sessions of the user that ran out.
+	if err := s.sessionRepo.PruneRotatedTokens(ctx, userId); err != nil {
+		log.Printf("[AUTH] failed to prune rotated refresh tokens of user %d: %v", userId, err)
+	}
+
+	accessToken, err := utils.GenerateAccessToken(s.authConfig.AppSecret, s.authConfig.AccessTokenTTL, userId, sessionId)
 	if err != nil {
 		return nil, errors.New("failed to generate access token")
 	}
 
 	return &models.LoginResponse{
-		AccessToken:  accessToken,
-		RefreshToken: refreshToken,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: expiresAt,
 	}, nil
 }
 
-func (s *AuthService) RefreshToken(ctx context.Context, req *models.RefreshTokenRequest) (string, error) {
-	claims, err := utils.VerifyRefreshToken(req.RefreshToken)
+// refreshReuseGrace is how long the refresh token rotated out last still
+// works, so two refreshes racing each other don't look like token theft.
+const refreshReuseGrace = 30 * time.Second
+
+// RefreshToken rotates the refresh token of a session and issues a new
+// access token. The new refresh token keeps the expiry of the one it
+// replaces, so a session can't be extended forever. Presenting a refresh
+// token that was already rotated, other than the last one within
+// refreshReuseGrace, revokes every session of the user, since either the
+// client or an attacker is holding a stolen copy.
+func (s *AuthService) RefreshToken(ctx context.Context, req *models.RefreshTokenRequest) (*models.LoginResponse, error) {
+	claims, err := utils.VerifyRefreshToken(s.authConfig.RefreshSecret, req.RefreshToken)
 	if err != nil {
-		return "", errors.New("invalid or expired refresh token")
+		return nil, errors.New("invalid or expired refresh token")
 	}
 
-	session, err := s.sessionRepo.GetByRefreshToken(ctx, req.RefreshToken)
+	refreshToken, expiresAt, err := utils.GenerateRefreshToken(s.authConfig.RefreshSecret, time.Until(claims.ExpiresAt.Time), claims.Id)
 	if err != nil {
-		return "", errors.New("invalid or expired refresh token")
+		return nil, errors.New("failed to generate refresh token")
+	}
+
+	oldHash, newHash := utils.HashToken(req.RefreshToken), utils.HashToken(refreshToken)
+	session, err := s.sessionRepo.Rotate(ctx, oldHash, newHash)
+	if err != nil && err.Error() == "session not found or expired" {
+		session, err = s.sessionRepo.RotatePrevious(ctx, oldHash, newHash, refreshReuseGrace)
+	}
+	if err != nil {
+		// Only a token that is no session's current one can be a stolen
+		// copy. Any other error says nothing about the token.
+		if err.Error() != "session not found or expired" {
+			return nil, err
+		}
+
+		rotated, err := s.sessionRepo.GetByRotatedToken(ctx, oldHash)
+		if err != nil {
+			if err.Error() != "session not found or expired" {
+				return nil, err
+			}
+			return nil, errors.New("invalid or expired refresh token")
+		}
+
+		log.Printf("[AUTH] refresh token reuse for user %d session %d, revoking all sessions", rotated.UserId, rotated.Id)
+		if err := s.sessionRepo.InvalidateAllByUserId(ctx, rotated.UserId); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("refresh token reuse detected")
 	}
 
-	accessToken, err := utils.GenerateAccessToken(claims.Id, session.Id)
+	accessToken, err := utils.GenerateAccessToken(s.authConfig.AppSecret, s.authConfig.AccessTokenTTL, session.UserId, session.Id)
 	if err != nil {
-		return "", errors.New("failed to generate access token")
+		return nil, errors.New("failed to generate access token")
 	}
 
-	return accessToken, nil
+	return &models.LoginResponse{
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: expiresAt,
+	}, nil
 }
 
 func (s *AuthService) Logout(ctx context.Context, req *models.LogoutRequest) error {
-	return s.sessionRepo.Invalidate(ctx, req.RefreshToken)
+	return s.sessionRepo.Invalidate(ctx, utils.HashToken(req.RefreshToken))
+}
+
+type oauthState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+// OAuthProviders returns the names of the configured login providers.
+func (s *AuthService) OAuthProviders() []string {
+	return oauth.Names(s.oauthProviders)
+}
+
+// StartOAuthLogin returns the URL of the provider's login page and the
+// state of this attempt, which the caller must bind to the browser.
+func (s *AuthService) StartOAuthLogin(ctx context.Context, providerName string) (string, string, error) {
+	provider, ok := s.oauthProviders[providerName]
+	if !ok {
+		return "", "", errors.New("unknown oauth provider")
+	}
+
+	state := rand.Text()
+	attempt := oauthState{
+		Provider: providerName,
+		Nonce:    rand.Text(),
+		Verifier: oauth.GenerateVerifier(),
+	}
+
+	authURL, err := provider.AuthCodeURL(ctx, state, attempt.Nonce, attempt.Verifier)
+	if err != nil {
+		log.Printf("[OAUTH] %s: %v", providerName, err)
+		return "", "", errors.New("oauth provider unavailable")
+	}
+
+	data, err := json.Marshal(attempt)
+	if err != nil {
+		return "", "", err
+	}
+	if err := config.Rdb.Set(ctx, "oauth:state:"+state, data, s.oauthStateTTL).Err(); err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// OAuthLogin finishes a login at an external provider and starts a session,
+// or asks for a two-factor code, like Login does. A provider account seen for the first time is linked to
+// the user with the same verified email, or to a new user.
+func (s *AuthService) OAuthLogin(ctx context.Context, providerName, code, state, ipAddress, userAgent string) (*models.LoginResponse, error) {
+	// A state can only be used once.
+	data, err := config.Rdb.GetDel(ctx, "oauth:state:"+state).Bytes()
+	if err != nil {
+		return nil, errors.New("invalid or expired oauth state")
+	}
+
+	var attempt oauthState
+	if err := json.Unmarshal(data, &attempt); err != nil || attempt.Provider != providerName {
+		return nil, errors.New("invalid or expired oauth state")
+	}
+
+	provider, ok := s.oauthProviders[providerName]
+	if !ok {
+		return nil, errors.New("unknown oauth provider")
+	}
+
+	identity, err := provider.Exchange(ctx, code, attempt.Nonce, attempt.Verifier)
+	if err != nil {
+		log.Printf("[OAUTH] %s: %v", providerName, err)
+		return nil, errors.New("oauth login failed")
+	}
+
+	userId, err := s.identityRepo.Login(ctx, providerName, identity.Subject, identity.Email)
+	if err != nil {
+		if err.Error() != "identity not found" {
+			return nil, err
+		}
+		userId, err = s.linkIdentity(ctx, providerName, identity)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s.completeLogin(ctx, userId, ipAddress, userAgent)
+}
+
+// linkIdentity links a provider account to the user owning its email,
+// creating the user when there is none. Only addresses the provider
+// verified are trusted.
+func (s *AuthService) linkIdentity(ctx context.Context, providerName string, identity *oauth.Identity) (int, error) {
+	if !identity.EmailVerified {
+		return 0, errors.New("oauth email not verified")
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, identity.Email)
+	switch {
+	case err == nil:
+		if user.EmailVerifiedAt == nil {
+			// Anyone can register an address they don't own and wait for
+			// the owner to log in with it. Lock out whoever chose the
+			// password, the owner can set one with ForgotPassword.
+			if err := s.lockPassword(ctx, user.Id); err != nil {
+				return 0, err
+			}
+			if err := s.sessionRepo.InvalidateAllByUserId(ctx, user.Id); err != nil {
+				return 0, err
+			}
+			if err := s.userRepo.MarkEmailVerified(ctx, user.Id); err != nil {
+				return 0, err
+			}
+		}
+	case err.Error() == "user not found":
+		user, err = s.createOAuthUser(ctx, identity)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, err
+	}
+
+	return s.identityRepo.Link(ctx, user.Id, providerName, identity.Subject, identity.Email)
+}
+
+// createOAuthUser creates a verified user without a usable password.
+func (s *AuthService) createOAuthUser(ctx context.Context, identity *oauth.Identity) (*models.User, error) {
+	fullName := identity.Name
+	if fullName == "" {
+		fullName, _, _ = strings.Cut(identity.Email, "@")
+	}
+
+	hashedPassword, err := utils.HashPassword(rand.Text())
+	if err != nil {
+		return nil, errors.New("failed to hash password")
+	}
+
+	user := &models.User{
+		FullName: truncate(fullName, 255),
+		Email:    identity.Email,
+		Password: hashedPassword,
+	}
+	if err := s.userRepo.Create(ctx, user); err != nil {
+		return nil, errors.New("failed to create user")
+	}
+
+	if err := s.userRepo.UpdateCreatedByAndUpdatedBy(ctx, user.Id); err != nil {
+		return nil, errors.New("failed to update user metadata")
+	}
+
+	if err := s.userRepo.MarkEmailVerified(ctx, user.Id); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// lockPassword replaces the password of a user with a random one nobody
+// knows.
+func (s *AuthService) lockPassword(ctx context.Context, userId int) error {
+	hashedPassword, err := utils.HashPassword(rand.Text())
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+	return s.userRepo.UpdatePassword(ctx, userId, hashedPassword)
+}
+
+// mailCooldown limits how often verification and reset emails can be
+// requested for the same address.
+const mailCooldown = time.Minute
+
+// mailSendTimeout bounds an email sent in the background, including the
+// creation of its token.
+const mailSendTimeout = time.Minute
+
+// SendVerificationEmail emails a link that confirms the user owns their
+// current address.
+func (s *AuthService) SendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := s.createUserToken(ctx, user.Id, repository.TokenPurposeVerifyEmail, s.authConfig.EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.frontendURL + "/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Hi " + user.FullName + ",\n\n" +
+			"Please confirm your email address by opening the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + s.authConfig.EmailVerificationTTL.String() + ". " +
+			"If you didn't create an account, you can ignore this email.\n",
+	})
+}
+
+func (s *AuthService) VerifyEmail(ctx context.Context, req *models.VerifyEmailRequest) error {
+	userId, err := s.userTokenRepo.Consume(ctx, repository.TokenPurposeVerifyEmail, utils.HashToken(req.Token))
+	if err != nil {
+		return err
+	}
+	return s.userRepo.MarkEmailVerified(ctx, userId)
+}
+
+// ResendVerification sends a new verification email. It reports success for
+// unknown or already verified addresses so it can't be used to probe which
+// emails are registered.
+func (s *AuthService) ResendVerification(ctx context.Context, req *models.EmailRequest) error {
+	user, ok := s.lookupForMail(ctx, "verify", req.Email)
+	if !ok || user.EmailVerifiedAt != nil {
+		return nil
+	}
+	s.sendInBackground("verification", user.Id, func(ctx context.Context) error {
+		return s.SendVerificationEmail(ctx, user)
+	})
+	return nil
+}
+
+// ForgotPassword emails a password reset link. Like ResendVerification it
+// never reveals whether the address is registered.
+func (s *AuthService) ForgotPassword(ctx context.Context, req *models.EmailRequest) error {
+	user, ok := s.lookupForMail(ctx, "reset", req.Email)
+	if !ok {
+		return nil
+	}
+	s.sendInBackground("password reset", user.Id, func(ctx context.Context) error {
+		return s.sendPasswordResetEmail(ctx, user)
+	})
+	return nil
+}
+
+func (s *AuthService) sendPasswordResetEmail(ctx context.Context, user *models.User) error {
+	token, err := s.createUserToken(ctx, user.Id, repository.TokenPurposeResetPassword, s.authConfig.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.frontendURL + "/reset-password?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.FullName + ",\n\n" +
+			"Someone asked to reset the password of your account. Open the link below to choose a new one:\n\n" +
+			link + "\n\n" +
+			"The link expires in " + s.authConfig.PasswordResetTTL.String() + ". " +
+			"If you didn't ask for this, you can ignore this email and your password stays the same.\n",
+	})
+}
+
+// sendInBackground sends an email after the request has been answered.
+// Talking to the mail server takes long enough to tell a registered address
+// from an unknown one by the response time alone.
+func (s *AuthService) sendInBackground(kind string, userId int, send func(ctx context.Context) error) {
+	s.mails.Add(1)
+	go func() {
+		defer s.mails.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := send(ctx); err != nil {
+			log.Printf("[MAIL] failed to send %s email to user %d: %v", kind, userId, err)
+		}
+	}()
+}
+
+// Shutdown waits until the emails sent in the background are out or ctx
+// expires.
+func (s *AuthService) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.mails.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ResetPassword sets a new password with a reset token and logs out every
+// session. Receiving the email also proves ownership of the address.
+func (s *AuthService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	hashedPassword, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	_, err = s.userRepo.ResetPassword(ctx, utils.HashToken(req.Token), hashedPassword)
+	return err
+}
+
+// lookupForMail finds the user to email and applies the per address
+// cooldown. kind separates the cooldowns of the different emails.
+func (s *AuthService) lookupForMail(ctx context.Context, kind, email string) (*models.User, bool) {
+	key := "mail:" + kind + ":" + strings.ToLower(email)
+	if ok, err := config.Rdb.SetNX(ctx, key, 1, mailCooldown).Result(); err == nil && !ok {
+		return nil, false
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, false
+	}
+	return user, true
+}
+
+func (s *AuthService) createUserToken(ctx context.Context, userId int, purpose string, ttl time.Duration) (string, error) {
+	token := rand.Text()
+	err := s.userTokenRepo.Create(ctx, userId, purpose, utils.HashToken(token), time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
 }
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/database"
+	"backend-koda-shortlink/internal/mailer"
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/oauth"
+	"backend-koda-shortlink/internal/oauth/oauthtest"
+	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/utils"
+	"context"
+	"crypto/rand"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+var migrateOnce sync.Once
+
+// newOAuthTestService returns an AuthService with one OIDC provider "test"
+// backed by an oauthtest.Server. Linking identities needs the real schema,
+// so the test runs against TEST_DATABASE_URL and TEST_REDIS_URL and is
+// skipped without them.
+func newOAuthTestService(t *testing.T) (*AuthService, *oauthtest.Server) {
+	t.Helper()
+
+	databaseURL, redisURL := os.Getenv("TEST_DATABASE_URL"), os.Getenv("TEST_REDIS_URL")
+	if databaseURL == "" || redisURL == "" {
+		t.Skip("TEST_DATABASE_URL and TEST_REDIS_URL are not set")
+	}
+
+	migrateOnce.Do(func() {
+		m, err := migrate.New("file://../../migrations", databaseURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Close()
+		if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+			t.Fatal(err)
+		}
+	})
+	database.InitDatabase(databaseURL)
+	config.InitRedis(redisURL)
+
+	srv := oauthtest.NewServer("koda", "koda-secret")
+	t.Cleanup(srv.Close)
+
+	providers, err := oauth.Open(config.OAuthConfig{
+		Providers: map[string]config.OAuthProviderConfig{
+			"test": {Type: "oidc", ClientID: srv.ClientID, ClientSecret: srv.ClientSecret, Issuer: srv.URL},
+		},
+	}, "http://localhost:8080/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mail, err := mailer.Open(config.MailConfig{Driver: "log", From: "Koda <no-reply@example.com>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authConfig := config.AuthConfig{
+		AppSecret:       "test-app-secret",
+		RefreshSecret:   "test-refresh-secret",
+		AccessTokenTTL:  15 * time.Minute,
+		RefreshTokenTTL: time.Hour,
+		LoginProtection: config.LoginProtectionConfig{
+			FreeAttempts:     5,
+			IPFreeAttempts:   20,
+			BaseDelay:        time.Second,
+			MaxDelay:         time.Minute,
+			LockoutThreshold: 10,
+			LockoutDuration:  15 * time.Minute,
+			FailureWindow:    15 * time.Minute,
+		},
+	}
+
+	s := NewAuthService(
+		repository.NewUserRepository(database.DB, time.Minute),
+		repository.NewSessionRepository(database.DB),
+		repository.NewUserTokenRepository(database.DB),
+		repository.NewUserIdentityRepository(database.DB),
+		repository.NewTwoFactorRepository(database.DB, utils.DeriveKey("test-totp-key", "totp-secret")),
+		mail,
+		providers,
+		authConfig,
+		time.Minute,
+		"http://localhost:5173/",
+	)
+	return s, srv
+}
+
+func testAccount(verified bool) oauthtest.Account {
+	id := strings.ToLower(rand.Text())
+	return oauthtest.Account{
+		Subject:       "sub-" + id,
+		Email:         "oauth-" + id + "@example.com",
+		EmailVerified: verified,
+		Name:          "OAuth Tester",
+	}
+}
+
+// startOAuth runs the part of a login that happens in the browser and
+// returns the code and state of the callback.
+func startOAuth(t *testing.T, s *AuthService, srv *oauthtest.Server, account oauthtest.Account) (string, string) {
+	t.Helper()
+
+	authURL, state, err := s.StartOAuthLogin(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, returnedState, err := srv.Authorize(authURL, account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if returnedState != state {
+		t.Fatalf("provider returned state %q, want %q", returnedState, state)
+	}
+	return code, state
+}
+
+func TestOAuthLoginStateRoundTrip(t *testing.T) {
+	s, srv := newOAuthTestService(t)
+	ctx := context.Background()
+	account := testAccount(true)
+
+	code, state := startOAuth(t, s, srv, account)
+
+	if _, err := s.OAuthLogin(ctx, "test", code, "forged-state", "203.0.113.1", "test"); err == nil || err.Error() != "invalid or expired oauth state" {
+		t.Fatalf("OAuthLogin() with a forged state error = %v", err)
+	}
+
+	resp, err := s.OAuthLogin(ctx, "test", code, state, "203.0.113.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.AccessToken == "" || resp.RefreshToken == "" {
+		t.Fatalf("OAuthLogin() = %+v, want tokens", resp)
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, account.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("user created from a verified provider email is not verified")
+	}
+
+	// The state is spent, even though the code is a valid one.
+	if _, err := s.OAuthLogin(ctx, "test", code, state, "203.0.113.1", "test"); err == nil || err.Error() != "invalid or expired oauth state" {
+		t.Fatalf("OAuthLogin() with a used state error = %v", err)
+	}
+
+	// The next login finds the linked identity.
+	code, state = startOAuth(t, s, srv, account)
+	if _, err := s.OAuthLogin(ctx, "test", code, state, "203.0.113.1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	again, err := s.userRepo.GetByEmail(ctx, account.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != user.Id {
+		t.Errorf("second login landed on user %d, want %d", again.Id, user.Id)
+	}
+}
+
+func TestOAuthLoginRejectsUnverifiedEmail(t *testing.T) {
+	s, srv := newOAuthTestService(t)
+	ctx := context.Background()
+	account := testAccount(false)
+
+	code, state := startOAuth(t, s, srv, account)
+	_, err := s.OAuthLogin(ctx, "test", code, state, "203.0.113.2", "test")
+	if err == nil || err.Error() != "oauth email not verified" {
+		t.Fatalf("OAuthLogin() error = %v, want oauth email not verified", err)
+	}
+
+	if _, err := s.userRepo.GetByEmail(ctx, account.Email); err == nil || err.Error() != "user not found" {
+		t.Errorf("GetByEmail() error = %v, want no user for an unverified email", err)
+	}
+}
+
+func TestOAuthLoginTakesOverUnverifiedAccount(t *testing.T) {
+	s, srv := newOAuthTestService(t)
+	ctx := context.Background()
+	account := testAccount(true)
+
+	// Someone registers the address before its owner and logs in.
+	squatter, err := s.Register(ctx, &models.RegisterRequest{
+		FullName: "Squatter",
+		Email:    account.Email,
+		Password: "squatter-password",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	login := &models.LoginRequest{Email: account.Email, Password: "squatter-password"}
+	squatterSession, err := s.Login(ctx, login, "203.0.113.3", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, state := startOAuth(t, s, srv, account)
+	if _, err := s.OAuthLogin(ctx, "test", code, state, "203.0.113.4", "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := s.userRepo.GetByEmail(ctx, account.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != squatter.Id {
+		t.Fatalf("identity linked to user %d, want the existing user %d", user.Id, squatter.Id)
+	}
+	if user.EmailVerifiedAt == nil {
+		t.Error("email not marked verified after the provider vouched for it")
+	}
+
+	if _, err := s.Login(ctx, login, "203.0.113.3", "test"); err == nil || err.Error() != "wrong email or password" {
+		t.Errorf("Login() with the squatter's password error = %v, want wrong email or password", err)
+	}
+	_, err = s.RefreshToken(ctx, &models.RefreshTokenRequest{RefreshToken: squatterSession.RefreshToken})
+	if err == nil {
+		t.Error("the squatter's session survived the takeover")
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	s, _ := newOAuthTestService(t)
+	ctx := context.Background()
+
+	email := "refresh-" + strings.ToLower(rand.Text()) + "@example.com"
+	if _, err := s.Register(ctx, &models.RegisterRequest{FullName: "Refresher", Email: email, Password: "refresh-password"}); err != nil {
+		t.Fatal(err)
+	}
+	login, err := s.Login(ctx, &models.LoginRequest{Email: email, Password: "refresh-password"}, "203.0.113.5", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := func(token string) (*models.LoginResponse, error) {
+		return s.RefreshToken(ctx, &models.RefreshTokenRequest{RefreshToken: token})
+	}
+
+	first, err := refresh(login.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A second refresh racing the first one still gets through.
+	second, err := refresh(login.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh with the token rotated out last error = %v", err)
+	}
+
+	// The first token is no longer the last one rotated out.
+	if _, err := refresh(login.RefreshToken); err == nil || err.Error() != "refresh token reuse detected" {
+		t.Fatalf("refresh with an older token error = %v, want refresh token reuse detected", err)
+	}
+	for _, token := range []string{first.RefreshToken, second.RefreshToken} {
+		if _, err := refresh(token); err == nil {
+			t.Error("a session survived the reuse of a refresh token")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/geoip"
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/repository"
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mssola/user_agent"
+)
+
+const clickFlushTimeout = 10 * time.Second
+
+// ClickService records redirects through a bounded in-process queue. A
+// single worker drains it and stores the clicks in batches, so redirect
+// traffic never holds more than one database connection for analytics.
+type ClickService struct {
+	clickRepo  *repository.ClickRepository
+	geoLocator geoip.Locator
+	cfg        config.ClickConfig
+
+	queue   chan models.Click
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+}
+
+func NewClickService(clickRepo *repository.ClickRepository, geoLocator geoip.Locator, cfg config.ClickConfig) *ClickService {
+	s := &ClickService{
+		clickRepo:  clickRepo,
+		geoLocator: geoLocator,
+		cfg:        cfg,
+		queue:      make(chan models.Click, cfg.QueueSize),
+		done:       make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s
+}
+
+// Record queues a click without blocking the redirect. When the queue is
+// full the click is dropped rather than slowing visitors down.
+func (s *ClickService) Record(req *http.Request, link *models.ShortLink) {
+	ip, _, _ := strings.Cut(req.Header.Get("X-Forwarded-For"), ",")
+	ip = strings.TrimSpace(ip)
+	if ip == "" {
+		ip, _, _ = net.SplitHostPort(req.RemoteAddr)
+	}
+
+	click := models.Click{
+		ShortLinkID: link.ID,
+		ShortCode:   link.ShortCode,
+		ClickedAt:   time.Now().UTC(),
+		IPAddress:   ip,
+		Referer:     req.Referer(),
+		UserAgent:   req.UserAgent(),
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.queue <- click:
+	default:
+		if dropped := s.dropped.Add(1); dropped%1000 == 1 {
+			log.Printf("[CLICKS] queue full, %d clicks dropped so far", dropped)
+		}
+	}
+}
+
+// Shutdown stops accepting clicks and waits until the queued ones are
+// stored or ctx expires.
+func (s *ClickService) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *ClickService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, s.cfg.BatchSize)
+	for {
+		select {
+		case click, ok := <-s.queue:
+			if !ok {
+				s.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= s.cfg.BatchSize {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				s.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+func (s *ClickService) flush(batch []models.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	for i := range batch {
+		s.enrich(&batch[i])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clickFlushTimeout)
+	defer cancel()
+
+	if err := s.clickRepo.SaveBatch(ctx, batch); err != nil {
+		log.Printf("[CLICKS] failed to save %d clicks: %v", len(batch), err)
+	}
+}
+
+// enrich fills the derived columns and fits every value into its column,
+// since one oversized value would make the whole COPY batch fail.
+func (s *ClickService) enrich(click *models.Click) {
+	ip := net.ParseIP(click.IPAddress)
+	click.IPAddress = ""
+	if ip != nil {
+		click.IPAddress = ip.String()
+	}
+
+	ua := user_agent.New(click.UserAgent)
+	browser, _ := ua.Browser()
+	click.Browser = truncate(browser, 50)
+	click.OS = truncate(ua.OS(), 50)
+
+	click.DeviceType = "desktop"
+	if ua.Mobile() {
+		click.DeviceType = "mobile"
+	}
+
+	location := s.geoLocator.Lookup(ip)
+	click.Country = truncate(location.Country, 100)
+	click.City = truncate(location.City, 100)
+}
+
+func truncate(value string, maxLen int) string {
+	runes := []rune(value)
+	if len(runes) <= maxLen {
+		return value
+	}
+	return string(runes[:maxLen])
+}
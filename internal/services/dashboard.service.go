@@ -3,6 +3,8 @@ package services
 import (
 	"backend-koda-shortlink/internal/repository"
 	"context"
+	"errors"
+	"time"
 )
 
 type DashboardService struct {
@@ -16,16 +18,73 @@ func NewDashboardService(repo *repository.DashboardRepository) *DashboardService
 }
 
 type DashboardStats struct {
-	TotalLinks    int
-	TotalVisits   int
-	AvgClickRate  float64
+	TotalLinks   int
+	TotalVisits  int
+	AvgClickRate float64
+	Range        repository.ChartRange
+	VisitsChart  []repository.VisitBucket
+	// Last7DaysStat mirrors VisitsChart for clients built against the former
+	// fixed 7 day chart.
 	Last7DaysStat any
 }
 
-func (s *DashboardService) Stats(ctx context.Context, userId int) (*DashboardStats, error) {
-	totalLinks, _ := s.repo.TotalLinks(ctx, userId)
-	totalVisits, _ := s.repo.TotalVisits(ctx, userId)
-	last7, _ := s.repo.Last7DaysChart(ctx, userId)
+var chartGranularities = map[string]time.Duration{
+	"hour":  time.Hour,
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 28 * 24 * time.Hour,
+}
+
+const maxChartBuckets = 1000
+
+func IsValidGranularity(granularity string) bool {
+	_, ok := chartGranularities[granularity]
+	return ok
+}
+
+// DefaultChartRange returns the range shown when the client doesn't pick one:
+// the last day for hourly charts, 7 days for daily, 12 weeks for weekly and
+// 12 months for monthly charts, ending with the current bucket.
+func DefaultChartRange(granularity string, loc *time.Location) (time.Time, time.Time) {
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	switch granularity {
+	case "hour":
+		end := now.Truncate(time.Hour).Add(time.Hour)
+		return end.Add(-24 * time.Hour), end
+	case "week":
+		end := today.AddDate(0, 0, 1)
+		return end.AddDate(0, 0, -7*12), end
+	case "month":
+		end := today.AddDate(0, 0, 1)
+		return time.Date(now.Year(), now.Month()-11, 1, 0, 0, 0, 0, loc), end
+	default:
+		end := today.AddDate(0, 0, 1)
+		return end.AddDate(0, 0, -7), end
+	}
+}
+
+func (s *DashboardService) Stats(ctx context.Context, userId int, rng repository.ChartRange) (*DashboardStats, error) {
+	if !rng.From.Before(rng.To) {
+		return nil, errors.New("invalid date range")
+	}
+	if rng.To.Sub(rng.From)/chartGranularities[rng.Granularity] > maxChartBuckets {
+		return nil, errors.New("date range too large for granularity")
+	}
+
+	totalLinks, err := s.repo.TotalLinks(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	totalVisits, err := s.repo.TotalVisits(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	chart, err := s.repo.VisitsChart(ctx, userId, rng)
+	if err != nil {
+		return nil, err
+	}
 
 	avgClickRate := 0.0
 	if totalLinks > 0 {
@@ -36,6 +95,8 @@ func (s *DashboardService) Stats(ctx context.Context, userId int) (*DashboardSta
 		TotalLinks:    totalLinks,
 		TotalVisits:   totalVisits,
 		AvgClickRate:  avgClickRate,
-		Last7DaysStat: last7,
+		Range:         rng,
+		VisitsChart:   chart,
+		Last7DaysStat: chart,
 	}, nil
 }
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/utils"
+	"context"
+	"errors"
+	"time"
+)
+
+type ExportService struct {
+	shortLinkRepo *repository.ShortLinkRepository
+	clickRepo     *repository.ClickRepository
+	appURL        string
+}
+
+func NewExportService(shortLinkRepo *repository.ShortLinkRepository, clickRepo *repository.ClickRepository, appURL string) *ExportService {
+	return &ExportService{
+		shortLinkRepo: shortLinkRepo,
+		clickRepo:     clickRepo,
+		appURL:        appURL,
+	}
+}
+
+func (s *ExportService) ExportLinks(ctx context.Context, userId int, search, status string, w utils.RowWriter) error {
+	err := w.WriteHeader([]string{
+		"id", "shortCode", "shortUrl", "originalUrl", "isActive", "isProtected",
+		"clickCount", "lastClickedAt", "expiresAt", "maxClicks", "createdAt", "updatedAt",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.shortLinkRepo.StreamByUserIDWithFilter(ctx, userId, search, status, func(link *models.ShortLink) error {
+		return w.WriteRow([]any{
+			link.ID, link.ShortCode, s.appURL + link.ShortCode, link.OriginalURL, link.IsActive, link.IsProtected,
+			link.ClickCount, link.LastClickedAt, link.ExpiredAt, link.MaxClicks, link.CreatedAt, link.UpdatedAt,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
+
+// CheckLinkOwner makes sure a per-link export is only served to the owner,
+// before anything is written to the response.
+func (s *ExportService) CheckLinkOwner(ctx context.Context, userId int, shortCode string) error {
+	link, err := s.shortLinkRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return err
+	}
+	if link.UserID == nil || *link.UserID != userId {
+		return errors.New("unauthorized access")
+	}
+	return nil
+}
+
+func (s *ExportService) ExportClicks(ctx context.Context, userId int, shortCode string, from, to *time.Time, w utils.RowWriter) error {
+	err := w.WriteHeader([]string{
+		"id", "shortCode", "clickedAt", "ipAddress", "referer", "userAgent",
+		"country", "city", "deviceType", "browser", "os",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = s.clickRepo.StreamByUserID(ctx, userId, shortCode, from, to, func(click *models.Click) error {
+		return w.WriteRow([]any{
+			click.ID, click.ShortCode, click.ClickedAt, click.IPAddress, click.Referer, click.UserAgent,
+			click.Country, click.City, click.DeviceType, click.Browser, click.OS,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return w.Close()
+}
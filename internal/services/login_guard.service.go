@@ -0,0 +1,215 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LoginBlockedError is returned while an account is locked or a client has
+// to wait before its next login attempt.
+type LoginBlockedError struct {
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return "account locked"
+	}
+	return "too many login attempts"
+}
+
+// LoginGuard tracks failed password logins per account and per IP in Redis.
+// Unknown emails are tracked like real ones, so the responses don't reveal
+// which accounts exist.
+type LoginGuard struct {
+	cfg config.LoginProtectionConfig
+	// delays are the waits after the first, second, ... failure past the
+	// free attempts, up to the first one reaching MaxDelay, in
+	// milliseconds.
+	delays []any
+}
+
+func NewLoginGuard(cfg config.LoginProtectionConfig) *LoginGuard {
+	g := &LoginGuard{cfg: cfg}
+	for failures := 1; ; failures++ {
+		delay := g.backoff(failures, 0)
+		g.delays = append(g.delays, delay.Milliseconds())
+		if delay >= cfg.MaxDelay || failures > 30 {
+			break
+		}
+	}
+	return g
+}
+
+// incrWithExpiry increments a counter and starts its window on the first
+// increment in a single step, so a counter never lives without expiry.
+var incrWithExpiry = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// reserveLoginAttempt refuses an attempt while the account (KEYS[1]) is
+// locked or the account or IP has to wait (KEYS[4], KEYS[5]). Otherwise it
+// counts the attempt as a failure of both (KEYS[2], KEYS[3]) up front and
+// sets the waits for the next one, so concurrent attempts can't all get in
+// before the first failure is recorded. It returns the outcome, 0 allowed, 1
+// locked, 2 waiting, 3 locked by this attempt, and the account failures or
+// the milliseconds to wait.
+var reserveLoginAttempt = redis.NewScript(`
+local lock = redis.call("PTTL", KEYS[1])
+if lock > 0 then
+	return {1, lock}
+end
+local wait = math.max(redis.call("PTTL", KEYS[4]), redis.call("PTTL", KEYS[5]))
+if wait > 0 then
+	return {2, wait}
+end
+
+local function count(key)
+	local n = redis.call("INCR", key)
+	if n == 1 then
+		redis.call("PEXPIRE", key, ARGV[1])
+	end
+	return n
+end
+local account = count(KEYS[2])
+local ip = count(KEYS[3])
+
+-- Only reached when attempts raced past the threshold before the failure
+-- that hit it was recorded.
+if account > tonumber(ARGV[4]) then
+	redis.call("SET", KEYS[1], 1, "PX", ARGV[5])
+	redis.call("DEL", KEYS[2], KEYS[4])
+	return {3, tonumber(ARGV[5])}
+end
+
+local function delay(failures, free)
+	local past = failures - free
+	if past <= 0 then
+		return 0
+	end
+	return tonumber(ARGV[5 + math.min(past, #ARGV - 5)])
+end
+local accountDelay = delay(account, tonumber(ARGV[2]))
+if accountDelay > 0 then
+	redis.call("SET", KEYS[4], 1, "PX", accountDelay)
+end
+local ipDelay = delay(ip, tonumber(ARGV[3]))
+if ipDelay > 0 then
+	redis.call("SET", KEYS[5], 1, "PX", ipDelay)
+end
+return {0, account}
+`)
+
+// releaseAttempt takes back an attempt counted by reserveLoginAttempt,
+// unless its window already ended.
+var releaseAttempt = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return redis.call("DECR", KEYS[1])
+end
+return 0
+`)
+
+func loginAccountKey(kind, email string) string {
+	return "login:" + kind + ":account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func loginIPKey(kind, ip string) string {
+	return "login:" + kind + ":ip:" + ip
+}
+
+// Check refuses the attempt while the account is locked or the account or
+// IP has to wait after earlier failures. An attempt it lets through counts
+// as failed until RecordSuccess says otherwise.
+func (g *LoginGuard) Check(ctx context.Context, email, ip string) error {
+	keys := []string{
+		loginAccountKey("lock", email),
+		loginAccountKey("failures", email),
+		loginIPKey("failures", ip),
+		loginAccountKey("wait", email),
+		loginIPKey("wait", ip),
+	}
+	args := append([]any{
+		g.cfg.FailureWindow.Milliseconds(),
+		g.cfg.FreeAttempts,
+		g.cfg.IPFreeAttempts,
+		g.cfg.LockoutThreshold,
+		g.cfg.LockoutDuration.Milliseconds(),
+	}, g.delays...)
+
+	result, err := reserveLoginAttempt.Run(ctx, config.Rdb, keys, args...).Int64Slice()
+	if err != nil {
+		return err
+	}
+
+	retryAfter := time.Duration(result[1]) * time.Millisecond
+	switch result[0] {
+	case 1:
+		return &LoginBlockedError{Locked: true, RetryAfter: retryAfter}
+	case 2:
+		return &LoginBlockedError{RetryAfter: retryAfter}
+	case 3:
+		g.auditLock(email, ip)
+		return &LoginBlockedError{Locked: true, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RecordFailure confirms a failed login counted by Check. It returns a
+// LoginBlockedError when this failure locked the account.
+func (g *LoginGuard) RecordFailure(ctx context.Context, email, ip string) error {
+	failures, err := config.Rdb.Get(ctx, loginAccountKey("failures", email)).Int()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	if failures < g.cfg.LockoutThreshold {
+		return nil
+	}
+
+	locked, err := config.Rdb.SetNX(ctx, loginAccountKey("lock", email), 1, g.cfg.LockoutDuration).Result()
+	if err != nil {
+		return err
+	}
+	if locked {
+		config.Rdb.Del(ctx, loginAccountKey("failures", email), loginAccountKey("wait", email))
+		g.auditLock(email, ip)
+	}
+	return &LoginBlockedError{Locked: true, RetryAfter: g.cfg.LockoutDuration}
+}
+
+func (g *LoginGuard) auditLock(email, ip string) {
+	log.Printf("[AUDIT] login locked for account %q for %s after %d failed attempts, last one from %s",
+		strings.ToLower(strings.TrimSpace(email)), g.cfg.LockoutDuration, g.cfg.LockoutThreshold, ip)
+}
+
+// RecordSuccess forgets the failures of the account and takes back the
+// attempt counted for the IP. Earlier failures of the IP are kept, otherwise
+// logging into one's own account in between would reset the limit for
+// guessing others.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, email, ip string) {
+	config.Rdb.Del(ctx, loginAccountKey("failures", email), loginAccountKey("wait", email))
+	releaseAttempt.Run(ctx, config.Rdb, []string{loginIPKey("failures", ip)})
+}
+
+// backoff is the wait after the given number of failures: none for the free
+// attempts, then BaseDelay doubling with every failure up to MaxDelay.
+func (g *LoginGuard) backoff(failures, free int) time.Duration {
+	if failures <= free {
+		return 0
+	}
+
+	exponent := failures - free - 1
+	if exponent >= 30 {
+		return g.cfg.MaxDelay
+	}
+	return min(g.cfg.BaseDelay<<exponent, g.cfg.MaxDelay)
+}
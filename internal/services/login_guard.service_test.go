@@ -0,0 +1,203 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var testLoginProtection = config.LoginProtectionConfig{
+	FreeAttempts:     3,
+	IPFreeAttempts:   5,
+	BaseDelay:        time.Second,
+	MaxDelay:         10 * time.Second,
+	LockoutThreshold: 8,
+	LockoutDuration:  15 * time.Minute,
+	FailureWindow:    15 * time.Minute,
+}
+
+// useMiniredis points config.Rdb at a fresh in-memory Redis for the test.
+func useMiniredis(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	previous := config.Rdb
+	config.Rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		config.Rdb.Close()
+		config.Rdb = previous
+	})
+	return mr
+}
+
+func TestLoginGuardBackoff(t *testing.T) {
+	g := NewLoginGuard(testLoginProtection)
+
+	tests := []struct {
+		failures, free int
+		want           time.Duration
+	}{
+		{0, 3, 0},
+		{3, 3, 0},
+		{4, 3, time.Second},
+		{5, 3, 2 * time.Second},
+		{6, 3, 4 * time.Second},
+		{7, 3, 8 * time.Second},
+		{8, 3, 10 * time.Second},
+		{100, 3, 10 * time.Second},
+		{1, 0, time.Second},
+	}
+	for _, tc := range tests {
+		if got := g.backoff(tc.failures, tc.free); got != tc.want {
+			t.Errorf("backoff(%d, %d) = %s, want %s", tc.failures, tc.free, got, tc.want)
+		}
+	}
+
+	want := []any{int64(1000), int64(2000), int64(4000), int64(8000), int64(10000)}
+	if len(g.delays) != len(want) {
+		t.Fatalf("delays = %v, want %v", g.delays, want)
+	}
+	for i := range want {
+		if g.delays[i] != want[i] {
+			t.Errorf("delays = %v, want %v", g.delays, want)
+			break
+		}
+	}
+}
+
+// fail runs a login attempt with a wrong password through the guard.
+func fail(g *LoginGuard, email, ip string) error {
+	ctx := context.Background()
+	if err := g.Check(ctx, email, ip); err != nil {
+		return err
+	}
+	return g.RecordFailure(ctx, email, ip)
+}
+
+func blocked(t *testing.T, err error) *LoginBlockedError {
+	t.Helper()
+
+	var blockedErr *LoginBlockedError
+	if !errors.As(err, &blockedErr) {
+		t.Fatalf("error = %v, want a LoginBlockedError", err)
+	}
+	return blockedErr
+}
+
+func TestLoginGuardWaitsAfterFreeAttempts(t *testing.T) {
+	mr := useMiniredis(t)
+	g := NewLoginGuard(testLoginProtection)
+	const email, ip = "jane@example.com", "203.0.113.1"
+
+	for i := range testLoginProtection.FreeAttempts {
+		if err := fail(g, email, ip); err != nil {
+			t.Fatalf("failure %d: %v", i+1, err)
+		}
+	}
+
+	for _, wait := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second} {
+		if err := fail(g, email, ip); err != nil {
+			t.Fatal(err)
+		}
+		err := g.Check(context.Background(), email, ip)
+		if b := blocked(t, err); b.Locked || b.RetryAfter != wait {
+			t.Fatalf("Check() = %+v, want a wait of %s", b, wait)
+		}
+		mr.FastForward(wait)
+	}
+
+	// The account wait doesn't hold back another account from the same IP.
+	if err := g.Check(context.Background(), "john@example.com", ip); err != nil {
+		t.Fatalf("Check() of another account = %v", err)
+	}
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	mr := useMiniredis(t)
+	g := NewLoginGuard(testLoginProtection)
+	const email = "jane@example.com"
+
+	// Every failure from another IP, so only the account limit applies.
+	ips := []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4",
+		"203.0.113.5", "203.0.113.6", "203.0.113.7", "203.0.113.8"}
+	for i, ip := range ips {
+		err := fail(g, email, ip)
+		if i < len(ips)-1 {
+			if err != nil {
+				t.Fatalf("failure %d: %v", i+1, err)
+			}
+			mr.FastForward(testLoginProtection.MaxDelay)
+			continue
+		}
+		if b := blocked(t, err); !b.Locked || b.RetryAfter != testLoginProtection.LockoutDuration {
+			t.Fatalf("failure %d = %+v, want the account locked", i+1, b)
+		}
+	}
+
+	mr.FastForward(time.Minute)
+	err := g.Check(context.Background(), email, "198.51.100.1")
+	if b := blocked(t, err); !b.Locked || b.RetryAfter != testLoginProtection.LockoutDuration-time.Minute {
+		t.Fatalf("Check() = %+v, want the account locked", b)
+	}
+
+	mr.FastForward(testLoginProtection.LockoutDuration)
+	if err := g.Check(context.Background(), email, "198.51.100.1"); err != nil {
+		t.Fatalf("Check() after the lockout = %v", err)
+	}
+}
+
+func TestLoginGuardReservesConcurrentAttempts(t *testing.T) {
+	useMiniredis(t)
+	g := NewLoginGuard(testLoginProtection)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if g.Check(context.Background(), "jane@example.com", "203.0.113.1") == nil {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The free attempts and the first one that starts the backoff.
+	if want := testLoginProtection.FreeAttempts + 1; allowed != want {
+		t.Errorf("%d concurrent attempts got through, want %d", allowed, want)
+	}
+}
+
+func TestLoginGuardSuccess(t *testing.T) {
+	mr := useMiniredis(t)
+	g := NewLoginGuard(testLoginProtection)
+	const email, ip = "jane@example.com", "203.0.113.1"
+	ctx := context.Background()
+
+	for range 2 {
+		if err := fail(g, email, ip); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.Check(ctx, email, ip); err != nil {
+		t.Fatal(err)
+	}
+	g.RecordSuccess(ctx, email, ip)
+
+	if mr.Exists(loginAccountKey("failures", email)) {
+		t.Error("account failures kept after a successful login")
+	}
+	if got, _ := mr.Get(loginIPKey("failures", ip)); got != "2" {
+		t.Errorf("IP failures = %s, want the 2 failures without the successful attempt", got)
+	}
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/urlpolicy"
+	"backend-koda-shortlink/internal/utils"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	defaultQRSize   = 256
+	minQRSize       = 64
+	maxQRSize       = 2048
+	defaultQRMargin = 4
+	maxQRMargin     = 16
+	maxQRLogoBytes  = 2 << 20
+	// maxQRLogoDimension bounds the decoded logo, which is scaled down to
+	// a fifth of the code anyway.
+	maxQRLogoDimension = 4096
+)
+
+var qrLevels = map[string]qrcode.RecoveryLevel{
+	"l": qrcode.Low,
+	"m": qrcode.Medium,
+	"q": qrcode.High,
+	"h": qrcode.Highest,
+}
+
+// logoClient fetches logos from user supplied URLs. It refuses to connect
+// to private addresses, checked after DNS resolution so a public name can't
+// point it at internal services.
+var logoClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, c syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				addr, err := netip.ParseAddr(host)
+				if err != nil || urlpolicy.IsPrivateAddr(addr) {
+					return errors.New("logo host not allowed")
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+}
+
+type QRCodeService struct {
+	shortLinkRepo *repository.ShortLinkRepository
+	appURL        string
+	cacheTTL      time.Duration
+}
+
+func NewQRCodeService(shortLinkRepo *repository.ShortLinkRepository, appURL string, cacheTTL time.Duration) *QRCodeService {
+	return &QRCodeService{
+		shortLinkRepo: shortLinkRepo,
+		appURL:        appURL,
+		cacheTTL:      cacheTTL,
+	}
+}
+
+// QRCode renders the short URL of a link owned by userId and returns the
+// image with its content type. Rendered images are cached per option set.
+func (s *QRCodeService) QRCode(ctx context.Context, userId int, shortCode string, opts models.QRCodeOptions) ([]byte, string, error) {
+	style, level, err := qrCodeStyle(&opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	link, err := s.shortLinkRepo.GetByShortCode(ctx, shortCode)
+	if err != nil {
+		return nil, "", err
+	}
+	if link.UserID == nil || *link.UserID != userId {
+		return nil, "", errors.New("unauthorized access")
+	}
+
+	contentType := "image/png"
+	if opts.Format == "svg" {
+		contentType = "image/svg+xml"
+	}
+
+	cacheKey := qrCodeCacheKey(shortCode, opts)
+	if cached, err := config.Rdb.Get(ctx, cacheKey).Bytes(); err == nil {
+		return cached, contentType, nil
+	}
+
+	if opts.Logo != "" {
+		style.Logo, err = fetchLogo(ctx, opts.Logo)
+		if err != nil {
+			log.Printf("Failed to load QR code logo %s: %v", opts.Logo, err)
+			return nil, "", errors.New("failed to load logo")
+		}
+	}
+
+	code, err := qrcode.New(s.appURL+shortCode, level)
+	if err != nil {
+		return nil, "", err
+	}
+	code.DisableBorder = true
+
+	var data []byte
+	if opts.Format == "svg" {
+		data, err = utils.QRCodeSVG(code.Bitmap(), style)
+	} else {
+		data, err = utils.QRCodePNG(code.Bitmap(), style)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	config.Rdb.Set(ctx, cacheKey, data, s.cacheTTL)
+
+	return data, contentType, nil
+}
+
+// qrCodeStyle validates opts, filling in the defaults, and returns the
+// rendering style without the logo.
+func qrCodeStyle(opts *models.QRCodeOptions) (utils.QRCodeStyle, qrcode.RecoveryLevel, error) {
+	var style utils.QRCodeStyle
+
+	opts.Format = strings.ToLower(opts.Format)
+	if opts.Format == "" {
+		opts.Format = "png"
+	}
+	if opts.Format != "png" && opts.Format != "svg" {
+		return style, 0, errors.New("invalid qr format")
+	}
+
+	if opts.Size == 0 {
+		opts.Size = defaultQRSize
+	}
+	if opts.Size < minQRSize || opts.Size > maxQRSize {
+		return style, 0, errors.New("invalid qr size")
+	}
+
+	margin := defaultQRMargin
+	if opts.Margin != nil {
+		margin = *opts.Margin
+	}
+	if margin < 0 || margin > maxQRMargin {
+		return style, 0, errors.New("invalid qr margin")
+	}
+	opts.Margin = &margin
+
+	// A logo hides modules in the center, which only the higher levels
+	// can make up for.
+	opts.Level = strings.ToLower(opts.Level)
+	if opts.Level == "" {
+		opts.Level = "m"
+		if opts.Logo != "" {
+			opts.Level = "h"
+		}
+	}
+	level, ok := qrLevels[opts.Level]
+	if !ok {
+		return style, 0, errors.New("invalid error correction level")
+	}
+	if opts.Logo != "" && level < qrcode.High {
+		return style, 0, errors.New("logo requires error correction level q or h")
+	}
+
+	if opts.Foreground == "" {
+		opts.Foreground = "000000"
+	}
+	if opts.Background == "" {
+		opts.Background = "ffffff"
+	}
+	fg, err := utils.ParseHexColor(opts.Foreground)
+	if err != nil {
+		return style, 0, err
+	}
+	bg, err := utils.ParseHexColor(opts.Background)
+	if err != nil {
+		return style, 0, err
+	}
+
+	if opts.Logo != "" {
+		logoURL, err := url.Parse(opts.Logo)
+		if err != nil || (logoURL.Scheme != "http" && logoURL.Scheme != "https") || logoURL.Host == "" {
+			return style, 0, errors.New("invalid logo url")
+		}
+	}
+
+	style = utils.QRCodeStyle{
+		Size:       opts.Size,
+		Margin:     margin,
+		Foreground: fg,
+		Background: bg,
+	}
+	return style, level, nil
+}
+
+func qrCodeCacheKey(shortCode string, opts models.QRCodeOptions) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%s|%d|%d|%s|%s|%s|%s",
+		opts.Format, opts.Size, *opts.Margin, opts.Level,
+		strings.ToLower(strings.TrimPrefix(opts.Foreground, "#")),
+		strings.ToLower(strings.TrimPrefix(opts.Background, "#")),
+		opts.Logo,
+	))
+	return "link:" + shortCode + ":qr:" + hex.EncodeToString(sum[:16])
+}
+
+func fetchLogo(ctx context.Context, logoURL string) (image.Image, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, logoURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := logoClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxQRLogoBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxQRLogoBytes {
+		return nil, errors.New("logo too large")
+	}
+
+	// The dimensions are checked before decoding, a small file can still
+	// describe a huge image.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width > maxQRLogoDimension || cfg.Height > maxQRLogoDimension {
+		return nil, errors.New("logo dimensions too large")
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/repository"
+	"context"
+
+	"github.com/mssola/user_agent"
+)
+
+type SessionService struct {
+	sessionRepo *repository.SessionRepository
+}
+
+func NewSessionService(sessionRepo *repository.SessionRepository) *SessionService {
+	return &SessionService{
+		sessionRepo: sessionRepo,
+	}
+}
+
+// ListSessions returns the active sessions of a user with the device parsed
+// from the login user agent. currentSessionId marks the caller's session.
+func (s *SessionService) ListSessions(ctx context.Context, userId, currentSessionId int) ([]models.SessionInfo, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserId(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]models.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		ua := user_agent.New(session.UserAgent)
+		browser, version := ua.Browser()
+		if version != "" {
+			browser += " " + version
+		}
+
+		deviceType := "desktop"
+		if ua.Mobile() {
+			deviceType = "mobile"
+		}
+		if ua.Bot() {
+			deviceType = "bot"
+		}
+
+		result = append(result, models.SessionInfo{
+			Id:         session.Id,
+			IpAddress:  session.IpAddress,
+			UserAgent:  session.UserAgent,
+			Browser:    browser,
+			OS:         ua.OS(),
+			DeviceType: deviceType,
+			LoginTime:  session.LoginTime,
+			LastSeenAt: session.LastSeenAt,
+			ExpiredAt:  session.ExpiredAt,
+			Current:    session.Id == currentSessionId,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *SessionService) RevokeSession(ctx context.Context, userId, sessionId int) error {
+	return s.sessionRepo.InvalidateByIdAndUserId(ctx, sessionId, userId)
+}
+
+// RevokeOtherSessions logs the user out everywhere except currentSessionId.
+func (s *SessionService) RevokeOtherSessions(ctx context.Context, userId, currentSessionId int) (int64, error) {
+	return s.sessionRepo.InvalidateAllByUserIdExcept(ctx, userId, currentSessionId)
+}
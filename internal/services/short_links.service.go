@@ -1,38 +1,172 @@
 package services
 
 import (
+	"backend-koda-shortlink/internal/challenge"
 	"backend-koda-shortlink/internal/config"
 	"backend-koda-shortlink/internal/models"
 	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/urlpolicy"
 	"backend-koda-shortlink/internal/utils"
 	"context"
-	"encoding/json"
+	"crypto/rand"
 	"errors"
-	"net"
+	"log"
 	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/mssola/user_agent"
+	"github.com/matthewhartstonge/argon2"
+	"github.com/redis/go-redis/v9"
 )
 
 type ShortLinkService struct {
 	shortLinkRepo *repository.ShortLinkRepository
-	clickRepo     *repository.ClickRepository
+	clickService  *ClickService
+	verifier      challenge.Verifier
+	policy        *urlpolicy.Policy
+	anonymous     config.AnonymousLinksConfig
+	appSecret     string
 }
 
-func NewShortLinkService(shortLinkRepo *repository.ShortLinkRepository, clickRepo *repository.ClickRepository) *ShortLinkService {
+func NewShortLinkService(shortLinkRepo *repository.ShortLinkRepository, clickService *ClickService, verifier challenge.Verifier, policy *urlpolicy.Policy, anonymous config.AnonymousLinksConfig, appSecret string) *ShortLinkService {
 	return &ShortLinkService{
 		shortLinkRepo: shortLinkRepo,
-		clickRepo:     clickRepo,
+		clickService:  clickService,
+		verifier:      verifier,
+		policy:        policy,
+		anonymous:     anonymous,
+		appSecret:     appSecret,
 	}
 }
 
-func (s *ShortLinkService) CreateShortLink(ctx context.Context, userID int, req *models.CreateShortLinkRequest) (*models.ShortLink, error) {
-	shortCode, err := s.generateUniqueShortCode(ctx)
+var aliasPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,20}$`)
+
+// reservedAliases holds path segments that are already served by the router
+// or are likely to be in the future, so they can't be claimed as short codes.
+var reservedAliases = map[string]bool{
+	"api":       true,
+	"swagger":   true,
+	"docs":      true,
+	"auth":      true,
+	"login":     true,
+	"logout":    true,
+	"register":  true,
+	"dashboard": true,
+	"links":     true,
+	"users":     true,
+	"admin":     true,
+	"static":    true,
+	"assets":    true,
+	"uploads":   true,
+	"health":    true,
+}
+
+func validateAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return errors.New("invalid alias")
+	}
+	if reservedAliases[strings.ToLower(alias)] {
+		return errors.New("alias is reserved")
+	}
+	return nil
+}
+
+func validateExpiration(expiresAt *time.Time, maxClicks *int) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return errors.New("expiration must be in the future")
+	}
+	if maxClicks != nil && *maxClicks < 1 {
+		return errors.New("max clicks must be greater than zero")
+	}
+	return nil
+}
+
+// toUTC normalizes client supplied timestamps, since short_links.expired_at is
+// a timestamp without time zone and pgx would keep the wall clock as is.
+func toUTC(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func trimUTM(utm models.UTMParams) models.UTMParams {
+	return models.UTMParams{
+		Source:   strings.TrimSpace(utm.Source),
+		Medium:   strings.TrimSpace(utm.Medium),
+		Campaign: strings.TrimSpace(utm.Campaign),
+		Term:     strings.TrimSpace(utm.Term),
+		Content:  strings.TrimSpace(utm.Content),
+	}
+}
+
+func hashLinkPassword(password string) (string, error) {
+	if len(password) < 4 {
+		return "", errors.New("link password must be at least 4 characters")
+	}
+
+	hashed, err := utils.HashPassword(password)
 	if err != nil {
+		return "", errors.New("failed to hash password")
+	}
+	return hashed, nil
+}
+
+// CreateShortLink creates a link for userID, or an anonymous one when userID
+// is zero. Anonymous creators have to pass the challenge and stay within the
+// quota of their ip, and their links expire unless claimed in time.
+func (s *ShortLinkService) CreateShortLink(ctx context.Context, userID int, ip string, req *models.CreateShortLinkRequest) (*models.ShortLink, error) {
+	if err := validateExpiration(req.ExpiresAt, req.MaxClicks); err != nil {
+		return nil, err
+	}
+
+	req.OriginalURL = strings.TrimSpace(req.OriginalURL)
+	if err := s.policy.Check(ctx, req.OriginalURL); err != nil {
 		return nil, err
 	}
 
+	var passwordHash *string
+	if req.Password != "" {
+		if userID <= 0 {
+			return nil, errors.New("login required to protect link with password")
+		}
+		hashed, err := hashLinkPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = &hashed
+	}
+
+	var shortCode string
+	var err error
+
+	if req.Alias != "" {
+		if userID <= 0 {
+			return nil, errors.New("login required to use custom alias")
+		}
+		if err := validateAlias(req.Alias); err != nil {
+			return nil, err
+		}
+
+		exists, err := s.shortLinkRepo.CheckShortCodeExists(ctx, req.Alias)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, errors.New("short code already in use")
+		}
+		shortCode = req.Alias
+	} else {
+		shortCode, err = s.generateUniqueShortCode(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var createdBy *int
 	if userID > 0 {
 		createdBy = &userID
@@ -41,11 +175,35 @@ func (s *ShortLinkService) CreateShortLink(ctx context.Context, userID int, req
 	}
 
 	link := &models.ShortLink{
-		UserID:      createdBy,
-		ShortCode:   shortCode,
-		OriginalURL: req.OriginalURL,
-		CreatedBy:   createdBy,
-		UpdatedBy:   createdBy,
+		UserID:       createdBy,
+		ShortCode:    shortCode,
+		OriginalURL:  req.OriginalURL,
+		ExpiredAt:    toUTC(req.ExpiresAt),
+		MaxClicks:    req.MaxClicks,
+		Password:     passwordHash,
+		IsProtected:  passwordHash != nil,
+		CreatedBy:    createdBy,
+		UpdatedBy:    createdBy,
+		ForwardQuery: req.ForwardQuery,
+	}
+	if req.UTM != nil {
+		link.UTM = trimUTM(*req.UTM)
+	}
+
+	if userID <= 0 {
+		if err := s.checkAnonymousCreator(ctx, ip, req.ChallengeResponse); err != nil {
+			return nil, err
+		}
+
+		expiresAt := time.Now().Add(s.anonymous.TTL).UTC()
+		if link.ExpiredAt == nil || link.ExpiredAt.After(expiresAt) {
+			link.ExpiredAt = &expiresAt
+			link.ExpiresUnlessClaimed = true
+		}
+
+		link.ClaimToken = rand.Text()
+		claimTokenHash := utils.HashToken(link.ClaimToken)
+		link.ClaimTokenHash = &claimTokenHash
 	}
 
 	err = s.shortLinkRepo.Create(ctx, link)
@@ -56,6 +214,229 @@ func (s *ShortLinkService) CreateShortLink(ctx context.Context, userID int, req
 	return link, nil
 }
 
+// checkAnonymousCreator verifies the challenge response and counts the link
+// against the quota of ip. Only verified requests use up the quota.
+func (s *ShortLinkService) checkAnonymousCreator(ctx context.Context, ip, challengeResponse string) error {
+	ok, err := s.verifier.Verify(ctx, challengeResponse, ip)
+	if err != nil {
+		log.Printf("Failed to verify challenge response: %v", err)
+		return errors.New("verification unavailable")
+	}
+	if !ok {
+		if challengeResponse == "" {
+			return errors.New("verification required")
+		}
+		return errors.New("verification failed")
+	}
+
+	count, err := incrWithExpiry.Run(ctx, config.Rdb, []string{"links:anonymous:quota:" + ip}, s.anonymous.QuotaWindow.Milliseconds()).Int()
+	if err != nil {
+		return err
+	}
+	if count > s.anonymous.Quota {
+		return errors.New("anonymous link quota reached")
+	}
+
+	return nil
+}
+
+// AnonymousChallenge returns the challenge an anonymous client has to solve
+// before creating a link.
+func (s *ShortLinkService) AnonymousChallenge(ctx context.Context) (*models.AnonymousChallenge, error) {
+	return s.verifier.Challenge(ctx)
+}
+
+// ClaimShortLinks transfers the anonymous links created with the given claim
+// tokens to userID and returns their codes. Unknown, already claimed and
+// expired tokens are skipped.
+func (s *ShortLinkService) ClaimShortLinks(ctx context.Context, userID int, claimTokens []string) ([]string, error) {
+	seen := make(map[string]bool, len(claimTokens))
+	hashes := make([]string, 0, len(claimTokens))
+	for _, token := range claimTokens {
+		hash := utils.HashToken(strings.TrimSpace(token))
+		if !seen[hash] {
+			seen[hash] = true
+			hashes = append(hashes, hash)
+		}
+	}
+
+	return s.shortLinkRepo.Claim(ctx, userID, hashes)
+}
+
+const MaxBulkShortLinks = 1000
+
+// CreateShortLinksBulk validates every item, reserves codes for the whole
+// batch with a single existence check per round and inserts the links in one
+// transaction. Failures are reported per row instead of failing the batch.
+func (s *ShortLinkService) CreateShortLinksBulk(ctx context.Context, userID int, items []models.BulkShortLinkItem) ([]models.BulkShortLinkResult, error) {
+	if len(items) == 0 {
+		return nil, errors.New("no links to create")
+	}
+	if len(items) > MaxBulkShortLinks {
+		return nil, errors.New("too many links in one request")
+	}
+
+	results := make([]models.BulkShortLinkResult, len(items))
+	seenAliases := make(map[string]bool)
+	var aliases []string
+
+	for i, item := range items {
+		results[i] = models.BulkShortLinkResult{
+			Row:         i + 1,
+			OriginalUrl: strings.TrimSpace(item.OriginalURL),
+		}
+		alias := strings.TrimSpace(item.Alias)
+
+		if results[i].OriginalUrl == "" {
+			results[i].Error = "original url is required"
+			continue
+		}
+		if alias == "" {
+			continue
+		}
+		if err := validateAlias(alias); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		if seenAliases[alias] {
+			results[i].Error = "duplicate alias in request"
+			continue
+		}
+
+		seenAliases[alias] = true
+		aliases = append(aliases, alias)
+		results[i].ShortCode = alias
+	}
+
+	s.checkDestinations(ctx, results)
+
+	if len(aliases) > 0 {
+		taken, err := s.shortLinkRepo.ExistingShortCodes(ctx, aliases)
+		if err != nil {
+			return nil, err
+		}
+		for i := range results {
+			if results[i].Error == "" && taken[results[i].ShortCode] {
+				results[i].Error = "short code already in use"
+			}
+		}
+	}
+
+	var pending []int
+	for i := range results {
+		if results[i].Error == "" && results[i].ShortCode == "" {
+			pending = append(pending, i)
+		}
+	}
+	if err := s.assignShortCodes(ctx, results, pending, seenAliases); err != nil {
+		return nil, err
+	}
+
+	var createdBy *int
+	if userID > 0 {
+		createdBy = &userID
+	}
+
+	var rows []int
+	var links []*models.ShortLink
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+		rows = append(rows, i)
+		links = append(links, &models.ShortLink{
+			UserID:      createdBy,
+			ShortCode:   results[i].ShortCode,
+			OriginalURL: results[i].OriginalUrl,
+			CreatedBy:   createdBy,
+			UpdatedBy:   createdBy,
+		})
+	}
+
+	if len(links) > 0 {
+		rowErrors, err := s.shortLinkRepo.CreateBatch(ctx, links)
+		if err != nil {
+			return nil, err
+		}
+		for j, i := range rows {
+			if rowErrors[j] != nil {
+				results[i].Error = rowErrors[j].Error()
+			}
+		}
+	}
+
+	for i := range results {
+		results[i].Success = results[i].Error == ""
+		if !results[i].Success {
+			results[i].ShortCode = ""
+		}
+	}
+
+	return results, nil
+}
+
+// maxConcurrentURLChecks bounds the parallel policy checks of a batch, which
+// may each wait for a DNS lookup.
+const maxConcurrentURLChecks = 16
+
+// checkDestinations runs the URL policy for every row without an error yet.
+func (s *ShortLinkService) checkDestinations(ctx context.Context, results []models.BulkShortLinkResult) {
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentURLChecks)
+	for i := range results {
+		if results[i].Error != "" {
+			continue
+		}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(result *models.BulkShortLinkResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := s.policy.Check(ctx, result.OriginalUrl); err != nil {
+				result.Error = err.Error()
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+}
+
+// assignShortCodes generates random codes for the given result rows, checking
+// a whole round of candidates against the database at once.
+func (s *ShortLinkService) assignShortCodes(ctx context.Context, results []models.BulkShortLinkResult, pending []int, reserved map[string]bool) error {
+	maxAttempts := 5
+	for attempt := 0; attempt < maxAttempts && len(pending) > 0; attempt++ {
+		candidates := make([]string, len(pending))
+		for j := range pending {
+			candidates[j] = utils.GenerateRandomCode(6)
+		}
+
+		taken, err := s.shortLinkRepo.ExistingShortCodes(ctx, candidates)
+		if err != nil {
+			return err
+		}
+
+		var retry []int
+		for j, i := range pending {
+			code := candidates[j]
+			if taken[code] || reserved[code] {
+				retry = append(retry, i)
+				continue
+			}
+			reserved[code] = true
+			results[i].ShortCode = code
+		}
+		pending = retry
+	}
+
+	for _, i := range pending {
+		results[i].Error = "failed to generate unique short code"
+	}
+
+	return nil
+}
+
 func (s *ShortLinkService) GetUserLinksWithFilter(ctx context.Context, userID, page, limit int, search, status string) ([]models.ShortLink, int, error) {
 	offset := (page - 1) * limit
 	return s.shortLinkRepo.GetAllByUserIDWithFilter(ctx, userID, limit, offset, search, status)
@@ -79,23 +460,43 @@ func (s *ShortLinkService) UpdateShortLink(ctx context.Context, shortCode string
 	if err != nil {
 		return nil, err
 	}
-	if existing.UserID != &userID {
+	if existing.UserID == nil || *existing.UserID != userID {
 		return nil, errors.New("unauthorized access")
 	}
 
-	link := &models.ShortLink{
-		OriginalURL: "",
-		UpdatedBy:   &userID,
+	if err := validateExpiration(req.ExpiresAt, req.MaxClicks); err != nil {
+		return nil, err
 	}
+	if (req.ClearExpiresAt && req.ExpiresAt != nil) || (req.ClearMaxClicks && req.MaxClicks != nil) {
+		return nil, errors.New("cannot set and clear a limit at once")
+	}
+	req.ExpiresAt = toUTC(req.ExpiresAt)
 
 	if req.OriginalURL != nil {
-		link.OriginalURL = *req.OriginalURL
+		originalURL := strings.TrimSpace(*req.OriginalURL)
+		if err := s.policy.Check(ctx, originalURL); err != nil {
+			return nil, err
+		}
+		req.OriginalURL = &originalURL
+	}
+	if req.UTM != nil {
+		utm := trimUTM(*req.UTM)
+		req.UTM = &utm
 	}
-	if req.IsActive != nil {
-		link.IsActive = *req.IsActive
+
+	var passwordHash *string
+	if req.Password != nil {
+		hashed := ""
+		if *req.Password != "" {
+			hashed, err = hashLinkPassword(*req.Password)
+			if err != nil {
+				return nil, err
+			}
+		}
+		passwordHash = &hashed
 	}
 
-	err = s.shortLinkRepo.Update(ctx, shortCode, userID, link)
+	err = s.shortLinkRepo.Update(ctx, shortCode, userID, req, passwordHash)
 	if err != nil {
 		return nil, err
 	}
@@ -131,14 +532,6 @@ func (s *ShortLinkService) generateUniqueShortCode(ctx context.Context) (string,
 }
 
 func (s *ShortLinkService) ResolveShortCode(ctx context.Context, code string) (*models.ShortLink, error) {
-	cached, err := config.Rdb.Get(ctx, "link:"+code+":destination").Result()
-	if err == nil && cached != "" {
-		var link models.ShortLink
-		if json.Unmarshal([]byte(cached), &link) == nil {
-			return &link, nil
-		}
-	}
-
 	link, err := s.shortLinkRepo.GetByShortCode(ctx, code)
 	if err != nil {
 		return nil, err
@@ -148,47 +541,224 @@ func (s *ShortLinkService) ResolveShortCode(ctx context.Context, code string) (*
 		return nil, errors.New("short link inactive")
 	}
 
-	jsonData, _ := json.Marshal(link)
-	config.Rdb.Set(ctx, "link:"+code+":destination", jsonData, 15*time.Minute)
+	// The block lists may have changed since the link was created.
+	if err := s.policy.Recheck(ctx, link.OriginalURL); err != nil {
+		return nil, errors.New("short link blocked")
+	}
+
+	if link.ExpiredAt != nil && !time.Now().Before(*link.ExpiredAt) {
+		return nil, errors.New("short link expired")
+	}
+
+	// Spent budgets are refused early; the click itself is only counted by
+	// ReserveClick once the visitor is actually redirected.
+	if link.MaxClicks != nil {
+		redirects, err := config.Rdb.Get(ctx, redirectCountKey(code)).Int()
+		if err == nil && redirects >= *link.MaxClicks {
+			return nil, errors.New("short link click limit reached")
+		}
+	}
 
 	return link, nil
 }
 
-func (s *ShortLinkService) LogClick(code string) {
-	ctx := context.Background()
+func redirectCountKey(code string) string {
+	return "link:" + code + ":redirects"
+}
 
-	_ = s.shortLinkRepo.IncrementClick(ctx, code)
+// redirectCountTTL is how long the redirect counter of a link outlives its
+// last visit. By then click_count has long caught up, so the counter can be
+// seeded from it again.
+const redirectCountTTL = 24 * time.Hour
+
+// reserveRedirect counts a redirect against a click budget and undoes it when
+// the budget is spent. A missing counter is seeded with ARGV[2], or -1 is
+// returned so the caller can read the seed first. Every call extends the
+// life of the counter to ARGV[3] milliseconds.
+var reserveRedirect = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	if ARGV[2] == "" then
+		return -1
+	end
+	redis.call("SET", KEYS[1], ARGV[2], "NX")
+end
+local count = redis.call("INCR", KEYS[1])
+local reserved = 1
+if count > tonumber(ARGV[1]) then
+	redis.call("DECR", KEYS[1])
+	reserved = 0
+end
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return reserved
+`)
+
+// ReserveClick takes one click from the budget of a link with max clicks
+// before it is redirected. click_count is written in batches and lags
+// behind, so the budget is counted atomically in Redis, starting from
+// click_count the first time. Without Redis it falls back to click_count.
+func (s *ShortLinkService) ReserveClick(ctx context.Context, link *models.ShortLink) error {
+	if link.MaxClicks == nil {
+		return nil
+	}
+
+	// The counter is useless once the link expired.
+	ttl := redirectCountTTL
+	if link.ExpiredAt != nil {
+		ttl = max(min(ttl, time.Until(*link.ExpiredAt)), time.Millisecond)
+	}
+
+	key := []string{redirectCountKey(link.ShortCode)}
+	reserved, err := reserveRedirect.Run(ctx, config.Rdb, key, *link.MaxClicks, "", ttl.Milliseconds()).Int()
+	if err == nil && reserved == -1 {
+		var clicks int
+		clicks, err = s.shortLinkRepo.GetClickCount(ctx, link.ShortCode)
+		if err != nil {
+			return err
+		}
+		reserved, err = reserveRedirect.Run(ctx, config.Rdb, key, *link.MaxClicks, clicks, ttl.Milliseconds()).Int()
+	}
+	if err != nil {
+		log.Printf("click budget of %s unavailable: %v", link.ShortCode, err)
+
+		clicks, err := s.shortLinkRepo.GetClickCount(ctx, link.ShortCode)
+		if err != nil {
+			return err
+		}
+		if clicks >= *link.MaxClicks {
+			return errors.New("short link click limit reached")
+		}
+		return nil
+	}
+
+	if reserved == 0 {
+		return errors.New("short link click limit reached")
+	}
+	return nil
 }
 
-func (s *ShortLinkService) SaveClickAnalytics(req *http.Request, link *models.ShortLink) {
-	go func() {
-		ctx := context.Background()
+const (
+	maxUnlockAttempts   = 5
+	unlockAttemptWindow = 15 * time.Minute
+	unlockTokenTTL      = time.Hour
+)
+
+// UnlockShortLink checks the password of a protected link and returns a
+// signed token that lets the visitor through for unlockTokenTTL. Attempts
+// are counted per link and client IP before the password is checked, so
+// concurrent guesses can't all slip in under the limit.
+func (s *ShortLinkService) UnlockShortLink(ctx context.Context, code, password, ip string) (string, time.Duration, error) {
+	attemptsKey := "link:" + code + ":unlock:" + ip
+
+	attempts, err := incrWithExpiry.Run(ctx, config.Rdb, []string{attemptsKey}, unlockAttemptWindow.Milliseconds()).Int()
+	if err != nil {
+		return "", 0, err
+	}
+	if attempts > maxUnlockAttempts {
+		return "", 0, errors.New("too many unlock attempts")
+	}
+
+	linkID, hash, err := s.shortLinkRepo.GetPasswordHash(ctx, code)
+	if err != nil {
+		return "", 0, err
+	}
+	if hash == "" {
+		return "", 0, errors.New("short link is not protected")
+	}
+
+	isValid, err := argon2.VerifyEncoded([]byte(password), []byte(hash))
+	if err != nil || !isValid {
+		return "", 0, errors.New("wrong link password")
+	}
+
+	config.Rdb.Del(ctx, attemptsKey)
+
+	token, err := utils.GenerateLinkUnlockToken(s.appSecret, linkID, hash, unlockTokenTTL)
+	if err != nil {
+		return "", 0, errors.New("failed to generate unlock token")
+	}
+
+	return token, unlockTokenTTL, nil
+}
 
-		ua := user_agent.New(req.UserAgent())
-		browser, _ := ua.Browser()
+// IsUnlocked reports whether token was issued by UnlockShortLink for link
+// and its current password.
+func (s *ShortLinkService) IsUnlocked(ctx context.Context, token string, link *models.ShortLink) bool {
+	linkID, hash, err := s.shortLinkRepo.GetPasswordHash(ctx, link.ShortCode)
+	if err != nil || hash == "" || linkID != link.ID {
+		return false
+	}
+	return utils.VerifyLinkUnlockToken(s.appSecret, token, link.ID, hash)
+}
 
-		deviceType := "desktop"
-		if ua.Mobile() {
-			deviceType = "mobile"
+// Destination returns the URL a visit of link goes to: the original URL with
+// the UTM fields of the link and, if the link forwards queries, the
+// parameters of rawQuery, the query string of the visit. UTM fields win over
+// forwarded parameters of the same name, which win over the parameters of
+// the original URL. Without anything to add the original URL is returned
+// untouched.
+func (s *ShortLinkService) Destination(link *models.ShortLink, rawQuery string) string {
+	var utmPairs []string
+	utmKeys := make(map[string]bool)
+	for _, param := range [][2]string{
+		{"utm_source", link.UTM.Source},
+		{"utm_medium", link.UTM.Medium},
+		{"utm_campaign", link.UTM.Campaign},
+		{"utm_term", link.UTM.Term},
+		{"utm_content", link.UTM.Content},
+	} {
+		if param[1] != "" {
+			utmPairs = append(utmPairs, param[0]+"="+url.QueryEscape(param[1]))
+			utmKeys[param[0]] = true
 		}
+	}
 
-		ip := req.Header.Get("X-Forwarded-For")
-		if ip == "" {
-			ip, _, _ = net.SplitHostPort(req.RemoteAddr)
+	var forwarded []string
+	replaced := make(map[string]bool)
+	if link.ForwardQuery {
+		for pair := range strings.SplitSeq(rawQuery, "&") {
+			key := queryKey(pair)
+			if pair == "" || utmKeys[key] {
+				continue
+			}
+			forwarded = append(forwarded, pair)
+			replaced[key] = true
 		}
+	}
+
+	if len(utmPairs) == 0 && len(forwarded) == 0 {
+		return link.OriginalURL
+	}
+
+	destination, err := url.Parse(link.OriginalURL)
+	if err != nil {
+		return link.OriginalURL
+	}
 
-		click := &models.Click{
-			ShortLinkID: link.ID,
-			IPAddress:   ip,
-			Referer:     req.Referer(),
-			UserAgent:   req.UserAgent(),
-			Country:     "",
-			City:        "",
-			DeviceType:  deviceType,
-			Browser:     browser,
-			OS:          ua.OS(),
+	// The parameters of the original URL keep their order and encoding.
+	var pairs []string
+	for pair := range strings.SplitSeq(destination.RawQuery, "&") {
+		key := queryKey(pair)
+		if pair != "" && !replaced[key] && !utmKeys[key] {
+			pairs = append(pairs, pair)
 		}
+	}
+	pairs = append(pairs, forwarded...)
+	pairs = append(pairs, utmPairs...)
+
+	destination.RawQuery = strings.Join(pairs, "&")
+	destination.ForceQuery = false
+	return destination.String()
+}
+
+// queryKey returns the decoded name of a raw query parameter.
+func queryKey(pair string) string {
+	key, _, _ := strings.Cut(pair, "=")
+	if unescaped, err := url.QueryUnescape(key); err == nil {
+		return unescaped
+	}
+	return key
+}
 
-		_ = s.clickRepo.Insert(ctx, click)
-	}()
+func (s *ShortLinkService) RecordClick(req *http.Request, link *models.ShortLink) {
+	s.clickService.Record(req, link)
 }
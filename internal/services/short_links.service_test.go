@@ -0,0 +1,137 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/models"
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReserveClick(t *testing.T) {
+	mr := useMiniredis(t)
+	s := &ShortLinkService{}
+	ctx := context.Background()
+
+	maxClicks := 5
+	expiresAt := time.Now().Add(time.Hour)
+	tests := []struct {
+		name      string
+		expiredAt *time.Time
+		ttl       time.Duration
+	}{
+		{"without expiry", nil, redirectCountTTL},
+		{"expiring", &expiresAt, time.Hour},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			link := &models.ShortLink{ShortCode: "abc123", MaxClicks: &maxClicks, ExpiredAt: tc.expiredAt}
+			// A seeded counter keeps the database out of the test.
+			mr.Set(redirectCountKey(link.ShortCode), "3")
+
+			for range 2 {
+				if err := s.ReserveClick(ctx, link); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.ReserveClick(ctx, link); err == nil || err.Error() != "short link click limit reached" {
+				t.Fatalf("ReserveClick() past the budget = %v, want short link click limit reached", err)
+			}
+
+			if got, _ := mr.Get(redirectCountKey(link.ShortCode)); got != "5" {
+				t.Errorf("counter = %s, want 5", got)
+			}
+			if ttl := mr.TTL(redirectCountKey(link.ShortCode)); ttl <= tc.ttl-time.Minute || ttl > tc.ttl {
+				t.Errorf("counter TTL = %s, want about %s", ttl, tc.ttl)
+			}
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	s := &ShortLinkService{}
+	campaign := models.UTMParams{Source: "newsletter", Campaign: "spring sale"}
+
+	tests := []struct {
+		name     string
+		original string
+		utm      models.UTMParams
+		forward  bool
+		rawQuery string
+		want     string
+	}{
+		{
+			name:     "no utm and no forwarding",
+			original: "https://example.com/a%2Fb?q=x+y&utm_source=orig#top",
+			rawQuery: "ref=tw",
+			want:     "https://example.com/a%2Fb?q=x+y&utm_source=orig#top",
+		},
+		{
+			name:     "forwarding an empty query",
+			original: "https://example.com/path?b=2&a=1",
+			forward:  true,
+			want:     "https://example.com/path?b=2&a=1",
+		},
+		{
+			name:     "forwarding an empty query with utm",
+			original: "https://example.com/path?a=1",
+			utm:      campaign,
+			forward:  true,
+			want:     "https://example.com/path?a=1&utm_source=newsletter&utm_campaign=spring+sale",
+		},
+		{
+			name:     "utm over forwarded over original",
+			original: "https://example.com/?ref=orig&utm_source=orig&keep=1",
+			utm:      campaign,
+			forward:  true,
+			rawQuery: "ref=fwd&utm_source=fwd&extra=2",
+			want:     "https://example.com/?keep=1&ref=fwd&extra=2&utm_source=newsletter&utm_campaign=spring+sale",
+		},
+		{
+			name:     "forwarded without forwarding",
+			original: "https://example.com/?ref=orig",
+			utm:      campaign,
+			rawQuery: "ref=fwd",
+			want:     "https://example.com/?ref=orig&utm_source=newsletter&utm_campaign=spring+sale",
+		},
+		{
+			name:     "encoded keys",
+			original: "https://example.com/?utm%5Fsource=orig",
+			utm:      campaign,
+			forward:  true,
+			rawQuery: "utm%5Fsource=fwd&r%65f=1",
+			want:     "https://example.com/?r%65f=1&utm_source=newsletter&utm_campaign=spring+sale",
+		},
+		{
+			name:     "fragment",
+			original: "https://example.com/docs?a=1#section-2",
+			utm:      campaign,
+			forward:  true,
+			rawQuery: "b=2",
+			want:     "https://example.com/docs?a=1&b=2&utm_source=newsletter&utm_campaign=spring+sale#section-2",
+		},
+		{
+			name:     "fragment without query",
+			original: "https://example.com/docs#section-2",
+			forward:  true,
+			rawQuery: "b=2",
+			want:     "https://example.com/docs?b=2#section-2",
+		},
+		{
+			name:     "empty pairs",
+			original: "https://example.com/?&a=1&",
+			forward:  true,
+			rawQuery: "&&b=2&",
+			want:     "https://example.com/?a=1&b=2",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			link := &models.ShortLink{OriginalURL: tc.original, UTM: tc.utm, ForwardQuery: tc.forward}
+			if got := s.Destination(link, tc.rawQuery); got != tc.want {
+				t.Errorf("Destination() = %s\nwant %s", got, tc.want)
+			}
+		})
+	}
+}
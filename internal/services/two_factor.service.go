@@ -0,0 +1,245 @@
+package services
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"backend-koda-shortlink/internal/models"
+	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/utils"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/skip2/go-qrcode"
+)
+
+const (
+	twoFactorIssuer   = "Koda Shortlink"
+	recoveryCodeCount = 10
+	twoFactorQRSize   = 256
+	// Codes only have a million combinations, so every user gets a few
+	// wrong guesses per window no matter from where they come.
+	twoFactorMaxAttempts   = 5
+	twoFactorAttemptWindow = 15 * time.Minute
+)
+
+type TwoFactorService struct {
+	twoFactorRepo *repository.TwoFactorRepository
+	userRepo      *repository.UserRepository
+	sessionRepo   *repository.SessionRepository
+}
+
+func NewTwoFactorService(
+	twoFactorRepo *repository.TwoFactorRepository,
+	userRepo *repository.UserRepository,
+	sessionRepo *repository.SessionRepository,
+) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+	}
+}
+
+func (s *TwoFactorService) Status(ctx context.Context, userId int) (*models.TwoFactorStatus, error) {
+	twoFactor, err := s.twoFactorRepo.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.TwoFactorStatus{
+		Enabled:   twoFactor.EnabledAt != nil,
+		EnabledAt: twoFactor.EnabledAt,
+	}
+	if status.Enabled {
+		status.RecoveryCodesLeft, err = s.twoFactorRepo.CountRecoveryCodes(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return status, nil
+}
+
+// Enroll creates a new secret for the user to add to an authenticator app.
+// Two-factor authentication stays off until Confirm receives a code.
+func (s *TwoFactorService) Enroll(ctx context.Context, userId int, req *models.TwoFactorEnrollRequest) (*models.TwoFactorEnrollment, error) {
+	if err := verifyPassword(ctx, s.userRepo, userId, req.Password); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetById(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := utils.GenerateTOTPSecret()
+	if err := s.twoFactorRepo.SetPendingSecret(ctx, userId, secret); err != nil {
+		return nil, err
+	}
+
+	uri := utils.TOTPURI(secret, twoFactorIssuer, user.Email)
+	png, err := qrcode.Encode(uri, qrcode.Medium, twoFactorQRSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret:     secret,
+		OtpauthURI: uri,
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// Confirm turns two-factor authentication on once the user proved the app
+// produces valid codes, and returns the recovery codes. Other sessions are
+// logged out since they were started with the password alone.
+func (s *TwoFactorService) Confirm(ctx context.Context, userId, sessionId int, req *models.TwoFactorCodeRequest) ([]string, error) {
+	if err := countTwoFactorAttempt(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	twoFactor, err := s.twoFactorRepo.Get(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if twoFactor.EnabledAt != nil {
+		return nil, errors.New("two-factor already enabled")
+	}
+	if twoFactor.Secret == nil {
+		return nil, errors.New("two-factor enrollment not started")
+	}
+
+	step, ok := utils.VerifyTOTP(*twoFactor.Secret, req.Code, time.Now(), 0)
+	if !ok {
+		return nil, errors.New("invalid code")
+	}
+
+	codes, hashes := generateRecoveryCodes()
+	if err := s.twoFactorRepo.Enable(ctx, userId, step, hashes); err != nil {
+		return nil, err
+	}
+	resetTwoFactorAttempts(ctx, userId)
+
+	if _, err := s.sessionRepo.InvalidateAllByUserIdExcept(ctx, userId, sessionId); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, for example after
+// they were used up or lost.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userId int, req *models.TwoFactorCodeRequest) ([]string, error) {
+	if err := verifySecondFactor(ctx, s.twoFactorRepo, userId, req.Code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes := generateRecoveryCodes()
+	if err := s.twoFactorRepo.ReplaceRecoveryCodes(ctx, userId, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *TwoFactorService) Disable(ctx context.Context, userId int, req *models.TwoFactorDisableRequest) error {
+	if err := verifyPassword(ctx, s.userRepo, userId, req.Password); err != nil {
+		return err
+	}
+
+	if err := verifySecondFactor(ctx, s.twoFactorRepo, userId, req.Code); err != nil {
+		return err
+	}
+
+	return s.twoFactorRepo.Disable(ctx, userId)
+}
+
+// verifySecondFactor checks a code of the authenticator app or an unused
+// recovery code of a user with two-factor authentication. Both kinds can
+// only be used once.
+func verifySecondFactor(ctx context.Context, twoFactorRepo *repository.TwoFactorRepository, userId int, code string) error {
+	if err := countTwoFactorAttempt(ctx, userId); err != nil {
+		return err
+	}
+
+	twoFactor, err := twoFactorRepo.Get(ctx, userId)
+	if err != nil {
+		return err
+	}
+	if twoFactor.EnabledAt == nil || twoFactor.Secret == nil {
+		return errors.New("two-factor not enabled")
+	}
+
+	if step, ok := utils.VerifyTOTP(*twoFactor.Secret, code, time.Now(), twoFactor.LastStep); ok {
+		used, err := twoFactorRepo.UseStep(ctx, userId, step)
+		if err != nil {
+			return err
+		}
+		if used {
+			resetTwoFactorAttempts(ctx, userId)
+			return nil
+		}
+		return errors.New("invalid code")
+	}
+
+	used, err := twoFactorRepo.UseRecoveryCode(ctx, userId, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return errors.New("invalid code")
+	}
+
+	resetTwoFactorAttempts(ctx, userId)
+	return nil
+}
+
+// countTwoFactorAttempt counts a code attempt before it is checked, so
+// parallel guesses can't slip past the limit. The counter is created with
+// its expiry in the same transaction and a Redis failure refuses the
+// attempt.
+func countTwoFactorAttempt(ctx context.Context, userId int) error {
+	key := "2fa:attempts:" + strconv.Itoa(userId)
+
+	var attempts *redis.IntCmd
+	_, err := config.Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetNX(ctx, key, 0, twoFactorAttemptWindow)
+		attempts = pipe.Incr(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if attempts.Val() > twoFactorMaxAttempts {
+		return errors.New("too many attempts")
+	}
+	return nil
+}
+
+func resetTwoFactorAttempts(ctx context.Context, userId int) {
+	config.Rdb.Del(ctx, "2fa:attempts:"+strconv.Itoa(userId))
+}
+
+// generateRecoveryCodes returns new codes formatted for display and the
+// hashes to store.
+func generateRecoveryCodes() ([]string, []string) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code := rand.Text()[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = utils.HashToken(code)
+	}
+	return codes, hashes
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -3,19 +3,230 @@ package services
 import (
 	"backend-koda-shortlink/internal/models"
 	"backend-koda-shortlink/internal/repository"
+	"backend-koda-shortlink/internal/storage"
+	"backend-koda-shortlink/internal/utils"
+	"bytes"
 	"context"
+	"errors"
+	"image"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/matthewhartstonge/argon2"
 )
 
 type UserService struct {
-	userRepo *repository.UserRepository
+	userRepo    *repository.UserRepository
+	sessionRepo *repository.SessionRepository
+	store       storage.Storage
+	authService *AuthService
 }
 
-func NewUserService(userRepo *repository.UserRepository) *UserService {
+func NewUserService(userRepo *repository.UserRepository, sessionRepo *repository.SessionRepository, store storage.Storage, authService *AuthService) *UserService {
 	return &UserService{
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		store:       store,
+		authService: authService,
 	}
 }
 
 func (s *UserService) GetById(ctx context.Context, id int) (*models.User, error) {
 	return s.userRepo.GetById(ctx, id)
 }
+
+func (s *UserService) UpdateProfile(ctx context.Context, id int, req *models.UpdateProfileRequest) (*models.User, error) {
+	if req.FullName == nil && req.Email == nil {
+		return nil, errors.New("nothing to update")
+	}
+
+	if req.FullName != nil {
+		fullName := strings.TrimSpace(*req.FullName)
+		if fullName == "" {
+			return nil, errors.New("full name cannot be empty")
+		}
+		req.FullName = &fullName
+	}
+
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		req.Email = &email
+	}
+
+	user, emailChanged, err := s.userRepo.UpdateProfile(ctx, id, req.FullName, req.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	// A changed address loses its verification until the new one is confirmed.
+	if emailChanged {
+		if err := s.authService.SendVerificationEmail(ctx, user); err != nil {
+			log.Printf("[MAIL] failed to send verification email to user %d: %v", user.Id, err)
+		}
+	}
+
+	return user, nil
+}
+
+// ChangePassword replaces the password after checking the current one, logs
+// out every other session, keeping the one that made the change, and
+// revokes the API keys.
+func (s *UserService) ChangePassword(ctx context.Context, id, sessionId int, req *models.ChangePasswordRequest) error {
+	if err := verifyPassword(ctx, s.userRepo, id, req.CurrentPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := utils.HashPassword(req.NewPassword)
+	if err != nil {
+		return errors.New("failed to hash password")
+	}
+
+	return s.userRepo.ChangePassword(ctx, id, hashedPassword, sessionId)
+}
+
+func (s *UserService) DeleteAccount(ctx context.Context, id int, req *models.DeleteAccountRequest) error {
+	if err := verifyPassword(ctx, s.userRepo, id, req.Password); err != nil {
+		return err
+	}
+
+	user, err := s.userRepo.GetById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Delete(ctx, id, req.Links == "anonymize"); err != nil {
+		return err
+	}
+
+	s.deleteProfilePhoto(ctx, user.ProfilePhoto)
+	return nil
+}
+
+// verifyPassword makes sensitive account changes prove the password again.
+func verifyPassword(ctx context.Context, userRepo *repository.UserRepository, id int, password string) error {
+	hash, err := userRepo.GetPasswordHash(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	isValid, err := argon2.VerifyEncoded([]byte(password), []byte(hash))
+	if err != nil || !isValid {
+		return errors.New("wrong password")
+	}
+	return nil
+}
+
+const (
+	MaxProfilePhotoSize   = 5 << 20
+	maxProfilePhotoPixels = 40_000_000
+)
+
+// profilePhotoSizes are the square avatar sizes generated for every upload.
+// The largest one is stored in users.profile_photo, the others sit next to
+// it with the size in the name swapped.
+var profilePhotoSizes = []int{64, 128, 256, 512}
+
+var profilePhotoTypes = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// UploadProfilePhoto validates an uploaded image by its content, stores it
+// resized to every profile photo size and returns the URLs by size.
+func (s *UserService) UploadProfilePhoto(ctx context.Context, id int, data []byte) (map[string]string, error) {
+	if len(data) > MaxProfilePhotoSize {
+		return nil, errors.New("profile photo too large")
+	}
+
+	if _, ok := profilePhotoTypes[http.DetectContentType(data)]; !ok {
+		return nil, errors.New("unsupported image type")
+	}
+
+	imgConfig, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+	if imgConfig.Width*imgConfig.Height > maxProfilePhotoPixels {
+		return nil, errors.New("image dimensions too large")
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.New("invalid image")
+	}
+
+	// Keep transparency for formats that may have it.
+	ext, contentType := "jpg", "image/jpeg"
+	if format == "png" || format == "gif" {
+		ext, contentType = "png", "image/png"
+	}
+
+	base := "avatars/" + strconv.Itoa(id) + "/" + utils.GenerateRandomCode(16)
+	urls := make(map[string]string, len(profilePhotoSizes))
+	var stored []string
+	for _, size := range profilePhotoSizes {
+		encoded, err := utils.EncodeImage(utils.ResizeSquare(img, size), ext)
+		if err != nil {
+			s.deleteKeys(ctx, stored)
+			return nil, errors.New("failed to process image")
+		}
+
+		key := base + "-" + strconv.Itoa(size) + "." + ext
+		if err := s.store.Put(ctx, key, encoded, contentType); err != nil {
+			log.Printf("[STORAGE] failed to store %s: %v", key, err)
+			s.deleteKeys(ctx, stored)
+			return nil, errors.New("failed to store profile photo")
+		}
+		stored = append(stored, key)
+		urls[strconv.Itoa(size)] = s.store.URL(key)
+	}
+
+	largest := strconv.Itoa(profilePhotoSizes[len(profilePhotoSizes)-1])
+	previous, err := s.userRepo.UpdateProfilePhoto(ctx, id, urls[largest])
+	if err != nil {
+		s.deleteKeys(ctx, stored)
+		return nil, err
+	}
+
+	s.deleteProfilePhoto(ctx, previous)
+
+	return urls, nil
+}
+
+// deleteProfilePhoto removes every size of a photo previously stored by
+// UploadProfilePhoto. Photos outside the storage are left alone.
+func (s *UserService) deleteProfilePhoto(ctx context.Context, photoURL *string) {
+	if photoURL == nil {
+		return
+	}
+
+	key, ok := storage.KeyFromURL(s.store, *photoURL)
+	if !ok {
+		return
+	}
+
+	largest := "-" + strconv.Itoa(profilePhotoSizes[len(profilePhotoSizes)-1]) + "."
+	i := strings.LastIndex(key, largest)
+	if i < 0 {
+		return
+	}
+	base, ext := key[:i], key[i+len(largest):]
+
+	keys := make([]string, 0, len(profilePhotoSizes))
+	for _, size := range profilePhotoSizes {
+		keys = append(keys, base+"-"+strconv.Itoa(size)+"."+ext)
+	}
+	s.deleteKeys(ctx, keys)
+}
+
+func (s *UserService) deleteKeys(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("[STORAGE] failed to delete %s: %v", key, err)
+		}
+	}
+}
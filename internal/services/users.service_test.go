@@ -0,0 +1,179 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type storedPhoto struct {
+	key         string
+	data        []byte
+	contentType string
+}
+
+// recordingStorage records the photos put into it and refuses keys
+// containing failOn, so uploads can be stopped before the user is updated in
+// the database.
+type recordingStorage struct {
+	stored  []storedPhoto
+	deleted []string
+	failOn  string
+}
+
+func (s *recordingStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if s.failOn != "" && strings.Contains(key, s.failOn) {
+		return errors.New("bucket unavailable")
+	}
+	s.stored = append(s.stored, storedPhoto{key: key, data: data, contentType: contentType})
+	return nil
+}
+
+func (s *recordingStorage) Delete(ctx context.Context, key string) error {
+	s.deleted = append(s.deleted, key)
+	return nil
+}
+
+func (s *recordingStorage) URL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+func testPhoto(t *testing.T, format string, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// pngHeader returns a PNG holding nothing but a header announcing the given
+// dimensions, enough for image.DecodeConfig.
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], width)
+	binary.BigEndian.PutUint32(ihdr[4:], height)
+	ihdr[8], ihdr[9] = 8, 6
+
+	var buf bytes.Buffer
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	binary.Write(&buf, binary.BigEndian, uint32(len(ihdr)))
+	chunk := append([]byte("IHDR"), ihdr...)
+	buf.Write(chunk)
+	binary.Write(&buf, binary.BigEndian, crc32.ChecksumIEEE(chunk))
+	return buf.Bytes()
+}
+
+func TestUploadProfilePhotoRejectsInvalidContent(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"too large", make([]byte, MaxProfilePhotoSize+1), "profile photo too large"},
+		{"text", []byte("definitely not an image"), "unsupported image type"},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), "unsupported image type"},
+		{"pdf", []byte("%PDF-1.7\n"), "unsupported image type"},
+		{"truncated png", testPhoto(t, "png", 32, 32)[:40], "invalid image"},
+		{"huge dimensions", pngHeader(10000, 10000), "image dimensions too large"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &recordingStorage{}
+			s := &UserService{store: store}
+
+			_, err := s.UploadProfilePhoto(context.Background(), 1, tc.data)
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("UploadProfilePhoto() error = %v, want %q", err, tc.err)
+			}
+			if len(store.stored) != 0 {
+				t.Errorf("stored %d files for a rejected photo", len(store.stored))
+			}
+		})
+	}
+}
+
+func TestUploadProfilePhotoResizes(t *testing.T) {
+	tests := []struct {
+		format      string
+		ext         string
+		contentType string
+	}{
+		{"jpeg", "jpg", "image/jpeg"},
+		{"png", "png", "image/png"},
+		{"gif", "png", "image/png"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.format, func(t *testing.T) {
+			// Failing the largest size keeps the test away from the
+			// database and shows the smaller ones get cleaned up.
+			store := &recordingStorage{failOn: "-512."}
+			s := &UserService{store: store}
+
+			_, err := s.UploadProfilePhoto(context.Background(), 7, testPhoto(t, tc.format, 300, 200))
+			if err == nil || err.Error() != "failed to store profile photo" {
+				t.Fatalf("UploadProfilePhoto() error = %v, want failed to store profile photo", err)
+			}
+
+			if len(store.stored) != 3 {
+				t.Fatalf("stored %d files, want the 64, 128 and 256 px ones", len(store.stored))
+			}
+			for i, photo := range store.stored {
+				size := profilePhotoSizes[i]
+				suffix := "-" + strconv.Itoa(size) + "." + tc.ext
+				if !strings.HasPrefix(photo.key, "avatars/7/") || !strings.HasSuffix(photo.key, suffix) {
+					t.Errorf("key %q, want avatars/7/...%s", photo.key, suffix)
+				}
+				if photo.contentType != tc.contentType {
+					t.Errorf("content type of %q = %q, want %q", photo.key, photo.contentType, tc.contentType)
+				}
+
+				cfg, format, err := image.DecodeConfig(bytes.NewReader(photo.data))
+				if err != nil {
+					t.Fatalf("decode %q: %v", photo.key, err)
+				}
+				if format != strings.TrimPrefix(tc.contentType, "image/") || cfg.Width != size || cfg.Height != size {
+					t.Errorf("%q is a %dx%d %s, want a %dx%d square", photo.key, cfg.Width, cfg.Height, format, size, size)
+				}
+			}
+
+			if len(store.deleted) != len(store.stored) {
+				t.Fatalf("deleted %v after the failure, want every stored size", store.deleted)
+			}
+			for i, key := range store.deleted {
+				if key != store.stored[i].key {
+					t.Errorf("deleted %q, want %q", key, store.stored[i].key)
+				}
+			}
+		})
+	}
+}
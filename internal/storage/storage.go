@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"errors"
+	"strings"
+)
+
+// Storage keeps uploaded files under slash separated keys and serves them
+// from public URLs.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// Open returns the Storage selected by cfg.Driver. appURL is used to build
+// the public URL of local files when cfg.PublicURL is empty.
+func Open(cfg config.StorageConfig, appURL string) (Storage, error) {
+	switch cfg.Driver {
+	case "local":
+		publicURL := cfg.PublicURL
+		if publicURL == "" {
+			publicURL = appURL + strings.TrimPrefix(LocalRoute, "/")
+		}
+		return NewLocalStorage(cfg.LocalDir, publicURL)
+	case "s3":
+		return NewS3Storage(cfg.S3, cfg.PublicURL)
+	}
+	return nil, errors.New("unsupported storage driver " + cfg.Driver)
+}
+
+// KeyFromURL reverses Storage.URL. It returns false for URLs that don't
+// point into s, e.g. photos uploaded before the storage was reconfigured.
+func KeyFromURL(s Storage, url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, s.URL(""))
+	return key, ok && key != ""
+}
+
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
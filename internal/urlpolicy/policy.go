@@ -0,0 +1,236 @@
+package urlpolicy
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// resolveTimeout bounds the DNS lookup made to find private targets.
+const resolveTimeout = 2 * time.Second
+
+// Policy decides whether a link may point to a destination URL.
+type Policy struct {
+	schemes      map[string]bool
+	selfHost     string
+	blocked      []string
+	allowed      []string
+	blockPrivate bool
+	reputation   ReputationChecker
+	resolver     *net.Resolver
+}
+
+// Open builds the Policy described by cfg. Links to the host of appURL are
+// refused, since they would redirect to ourselves.
+func Open(cfg config.URLPolicyConfig, appURL string) (*Policy, error) {
+	var checker ReputationChecker = NoopChecker{}
+	if cfg.HashListPath != "" {
+		hashList, err := LoadHashList(cfg.HashListPath)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("URL hash list loaded: %d entries", hashList.Len())
+		checker = hashList
+	}
+
+	return New(cfg, appURL, checker)
+}
+
+func New(cfg config.URLPolicyConfig, appURL string, checker ReputationChecker) (*Policy, error) {
+	self, err := url.Parse(appURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{
+		schemes:      make(map[string]bool, len(cfg.AllowedSchemes)),
+		selfHost:     normalizeHost(self.Hostname()),
+		blocked:      normalizeDomains(cfg.BlockedDomains),
+		allowed:      normalizeDomains(cfg.AllowedDomains),
+		blockPrivate: cfg.BlockPrivateTargets,
+		reputation:   checker,
+		resolver:     net.DefaultResolver,
+	}
+	for _, scheme := range cfg.AllowedSchemes {
+		p.schemes[strings.ToLower(scheme)] = true
+	}
+
+	return p, nil
+}
+
+// Check runs every rule against rawURL, resolving its host to find targets
+// in private networks. It is meant for new and changed destinations.
+func (p *Policy) Check(ctx context.Context, rawURL string) error {
+	return p.check(ctx, rawURL, true)
+}
+
+// Recheck runs the rules again for a stored destination, so links created
+// before a domain was blocked stop working. It skips the DNS lookup to keep
+// redirects fast.
+func (p *Policy) Recheck(ctx context.Context, rawURL string) error {
+	return p.check(ctx, rawURL, false)
+}
+
+func (p *Policy) check(ctx context.Context, rawURL string, resolve bool) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Scheme == "" {
+		return errors.New("invalid url")
+	}
+
+	if !p.schemes[strings.ToLower(u.Scheme)] {
+		return errors.New("url scheme not allowed")
+	}
+
+	// mailto: and the like have no host to check. Any other scheme needs
+	// one: browsers read "http:evil.com" as http://evil.com/, so an opaque
+	// http URL would slip past every host rule.
+	if opaqueSchemes[strings.ToLower(u.Scheme)] {
+		if u.Opaque == "" {
+			return errors.New("invalid url")
+		}
+		return p.checkReputation(ctx, u)
+	}
+	if u.Opaque != "" || u.Host == "" {
+		return errors.New("invalid url")
+	}
+
+	host := normalizeHost(u.Hostname())
+	if host == "" || isNumericShorthand(host) {
+		return errors.New("invalid url")
+	}
+
+	if host == p.selfHost {
+		return errors.New("url points to this shortener")
+	}
+
+	if len(p.allowed) > 0 && !matchesDomain(host, p.allowed) {
+		return errors.New("domain is not allowed")
+	}
+	if matchesDomain(host, p.blocked) {
+		return errors.New("domain is blocked")
+	}
+
+	if p.blockPrivate {
+		private, err := p.isPrivateHost(ctx, host, resolve)
+		if err != nil {
+			return err
+		}
+		if private {
+			return errors.New("url points to a private network")
+		}
+	}
+
+	return p.checkReputation(ctx, u)
+}
+
+// opaqueSchemes are the schemes whose URLs carry no host.
+var opaqueSchemes = map[string]bool{
+	"mailto": true,
+	"tel":    true,
+	"sms":    true,
+}
+
+// checkReputation fails open: a checker that can't answer is logged and
+// the URL let through.
+func (p *Policy) checkReputation(ctx context.Context, u *url.URL) error {
+	listed, err := p.reputation.Listed(ctx, u)
+	if err != nil {
+		log.Printf("URL reputation check failed for %s: %v", u.Redacted(), err)
+		return nil
+	}
+	if listed {
+		return errors.New("url is flagged as unsafe")
+	}
+	return nil
+}
+
+// isPrivateHost reports whether host is a local name or an address outside
+// the public internet. With resolve set, names are looked up and count as
+// private when any of their addresses is. Names that don't resolve are let
+// through, they can't reach anything either.
+func (p *Policy) isPrivateHost(ctx context.Context, host string, resolve bool) (bool, error) {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return IsPrivateAddr(addr), nil
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") ||
+		strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".internal") {
+		return true, nil
+	}
+
+	if !resolve {
+		return false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	addrs, err := p.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return false, nil
+	}
+	for _, addr := range addrs {
+		if IsPrivateAddr(addr) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isNumericShorthand reports hosts like "2130706433" or "0x7f.1" that
+// browsers read as IPv4 addresses but netip doesn't. No real domain ends in
+// a numeric label.
+func isNumericShorthand(host string) bool {
+	if _, err := netip.ParseAddr(host); err == nil {
+		return false
+	}
+
+	last := host[strings.LastIndex(host, ".")+1:]
+	if strings.HasPrefix(last, "0x") {
+		return true
+	}
+	return last != "" && strings.Trim(last, "0123456789") == ""
+}
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// IsPrivateAddr reports whether addr is outside the public internet.
+func IsPrivateAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		sharedAddressSpace.Contains(addr)
+}
+
+func normalizeHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(normalizeHost(strings.TrimSpace(domain)), "*.")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of one.
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
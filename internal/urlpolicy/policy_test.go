@@ -0,0 +1,218 @@
+package urlpolicy
+
+import (
+	"backend-koda-shortlink/internal/config"
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+// offlineResolver fails every lookup at once, so tests don't depend on DNS.
+var offlineResolver = &net.Resolver{
+	PreferGo: true,
+	Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		return nil, errors.New("offline")
+	},
+}
+
+func newTestPolicy(t *testing.T, cfg config.URLPolicyConfig) *Policy {
+	t.Helper()
+
+	p, err := New(cfg, "http://localhost:8081/", NoopChecker{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.resolver = offlineResolver
+	return p
+}
+
+func TestPolicyCheck(t *testing.T) {
+	p := newTestPolicy(t, config.URLPolicyConfig{
+		AllowedSchemes:      []string{"http", "https", "mailto"},
+		BlockedDomains:      []string{"evil.com", "*.bad.example"},
+		BlockPrivateTargets: true,
+	})
+
+	tests := []struct {
+		url string
+		err string
+	}{
+		{"https://example.com/page?q=1", ""},
+		{"http://notevil.com/", ""},
+		{"mailto:someone@example.com", ""},
+
+		{"evil.com", "invalid url"},
+		{"http://evil.com/path", "domain is blocked"},
+		{"https://WWW.Evil.com./", "domain is blocked"},
+		{"https://login.bad.example/", "domain is blocked"},
+		{"ftp://example.com/file", "url scheme not allowed"},
+		{"javascript:alert(1)", "url scheme not allowed"},
+
+		// Opaque and host-less forms browsers still resolve to a host.
+		{"http:evil.com", "invalid url"},
+		{"https:evil.com/path", "invalid url"},
+		{"http:127.0.0.1/admin", "invalid url"},
+		{"http:localhost:8081/abc", "invalid url"},
+		{"http:/evil.com", "invalid url"},
+		{"http:///evil.com", "invalid url"},
+		{"mailto://", "invalid url"},
+
+		{"http://localhost:8081/abc", "url points to this shortener"},
+		{"http://LOCALHOST:9999/", "url points to this shortener"},
+		{"http://127.0.0.1/admin", "url points to a private network"},
+		{"http://[::1]:8080/", "url points to a private network"},
+		{"http://10.1.2.3/", "url points to a private network"},
+		{"http://printer.local/", "url points to a private network"},
+		{"http://2130706433/", "invalid url"},
+		{"http://0x7f.1/", "invalid url"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			err := p.Check(context.Background(), tc.url)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("Check(%q) = %v, want nil", tc.url, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.err {
+				t.Fatalf("Check(%q) = %v, want %q", tc.url, err, tc.err)
+			}
+			if recheck := p.Recheck(context.Background(), tc.url); recheck == nil {
+				t.Errorf("Recheck(%q) let the URL through", tc.url)
+			}
+		})
+	}
+}
+
+func TestPolicyAllowedDomains(t *testing.T) {
+	p := newTestPolicy(t, config.URLPolicyConfig{
+		AllowedSchemes: []string{"http", "https"},
+		AllowedDomains: []string{"example.com"},
+	})
+
+	for url, allowed := range map[string]bool{
+		"https://example.com/":        true,
+		"https://docs.example.com/":   true,
+		"https://notexample.com/":     false,
+		"https://example.com.evil.io": false,
+	} {
+		err := p.Check(context.Background(), url)
+		if allowed != (err == nil) {
+			t.Errorf("Check(%q) = %v, want allowed %v", url, err, allowed)
+		}
+	}
+}
+
+func TestIsNumericShorthand(t *testing.T) {
+	tests := map[string]bool{
+		"2130706433":  true,
+		"0x7f.1":      true,
+		"127.1":       true,
+		"0x7f000001":  true,
+		"10.0.1":      true,
+		"127.0.0.1":   false,
+		"::1":         false,
+		"example.com": false,
+		"1.example":   false,
+		"a1b2.com":    false,
+	}
+	for host, want := range tests {
+		if got := isNumericShorthand(host); got != want {
+			t.Errorf("isNumericShorthand(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestMatchesDomain(t *testing.T) {
+	domains := []string{"evil.com", "bad.example"}
+	tests := map[string]bool{
+		"evil.com":         true,
+		"www.evil.com":     true,
+		"a.b.evil.com":     true,
+		"bad.example":      true,
+		"notevil.com":      false,
+		"evil.com.io":      false,
+		"evil.co":          false,
+		"example":          false,
+		"sub.bad.example2": false,
+	}
+	for host, want := range tests {
+		if got := matchesDomain(host, domains); got != want {
+			t.Errorf("matchesDomain(%q) = %v, want %v", host, got, want)
+		}
+	}
+	if matchesDomain("evil.com", nil) {
+		t.Error("matchesDomain() matched an empty list")
+	}
+}
+
+func TestIsPrivateAddr(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1":        true,
+		"10.0.0.1":         true,
+		"172.16.5.4":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"224.0.0.1":        true,
+		"::1":              true,
+		"::":               true,
+		"fe80::1":          true,
+		"fc00::1":          true,
+		"::ffff:127.0.0.1": true,
+		"::ffff:10.0.0.1":  true,
+		"8.8.8.8":          false,
+		"100.128.0.1":      false,
+		"172.32.0.1":       false,
+		"2606:4700::1111":  false,
+	}
+	for addr, want := range tests {
+		if got := IsPrivateAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("IsPrivateAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestLookupExpressions(t *testing.T) {
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{
+			"https://a.b.Example.com/Path?q=1",
+			[]string{"a.b.example.com", "b.example.com", "example.com", "a.b.example.com/Path", "a.b.example.com/Path?q=1"},
+		},
+		{
+			"http://example.com",
+			[]string{"example.com", "example.com/"},
+		},
+		{
+			"http://192.168.1.10:8080/x",
+			[]string{"192.168.1.10", "192.168.1.10/x"},
+		},
+		{
+			"http://example.com/a%20b",
+			[]string{"example.com", "example.com/a%20b"},
+		},
+		{
+			"MAILTO:Someone@Example.com",
+			[]string{"mailto:someone@example.com"},
+		},
+	}
+	for _, tc := range tests {
+		u, err := url.Parse(tc.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := lookupExpressions(u); !slices.Equal(got, tc.want) {
+			t.Errorf("lookupExpressions(%q) = %q, want %q", tc.url, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package urlpolicy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ReputationChecker looks destinations up in a list of known malicious or
+// spam URLs. An error means the lookup itself failed.
+type ReputationChecker interface {
+	Listed(ctx context.Context, u *url.URL) (bool, error)
+}
+
+// NoopChecker lists nothing.
+type NoopChecker struct{}
+
+func (NoopChecker) Listed(ctx context.Context, u *url.URL) (bool, error) {
+	return false, nil
+}
+
+// HashList is a local list of SHA-256 hashes, so it can be shared without
+// spelling out the listed URLs. An entry is the hash of a lowercase host,
+// which also lists its subdomains, or of host and path like
+// "example.com/phish", optionally with the query.
+type HashList struct {
+	hashes map[[sha256.Size]byte]bool
+}
+
+// LoadHashList reads one hex hash per line. Blank lines and lines starting
+// with # are skipped.
+func LoadHashList(path string) (*HashList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := &HashList{hashes: make(map[[sha256.Size]byte]bool)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		var hash [sha256.Size]byte
+		if n, err := hex.Decode(hash[:], []byte(entry)); err != nil || n != sha256.Size {
+			return nil, fmt.Errorf("%s:%d: not a hex SHA-256 hash", path, line)
+		}
+		list.hashes[hash] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+func (l *HashList) Len() int {
+	return len(l.hashes)
+}
+
+func (l *HashList) Listed(ctx context.Context, u *url.URL) (bool, error) {
+	for _, expr := range lookupExpressions(u) {
+		if l.hashes[sha256.Sum256([]byte(expr))] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// lookupExpressions returns the host with each of its parent domains, and
+// the full host with the path, with and without the query.
+func lookupExpressions(u *url.URL) []string {
+	if u.Opaque != "" {
+		return []string{strings.ToLower(u.Scheme + ":" + u.Opaque)}
+	}
+
+	host := normalizeHost(u.Hostname())
+	exprs := []string{host}
+	if _, err := netip.ParseAddr(host); err != nil {
+		for suffix := host; ; {
+			_, parent, _ := strings.Cut(suffix, ".")
+			if !strings.Contains(parent, ".") {
+				break
+			}
+			exprs = append(exprs, parent)
+			suffix = parent
+		}
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	exprs = append(exprs, host+path)
+	if u.RawQuery != "" {
+		exprs = append(exprs, host+path+"?"+u.RawQuery)
+	}
+
+	return exprs
+}
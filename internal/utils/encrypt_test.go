@@ -0,0 +1,52 @@
+package utils
+
+import "testing"
+
+func TestEncryptSecret(t *testing.T) {
+	key := DeriveKey("app-secret", "totp-secret")
+	const plaintext, ad = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", "totp_secret:1"
+
+	encrypted, err := EncryptSecret(key, plaintext, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsEncrypted(encrypted) || IsEncrypted(plaintext) {
+		t.Fatalf("IsEncrypted() can't tell %q from %q", encrypted, plaintext)
+	}
+	if again, _ := EncryptSecret(key, plaintext, ad); again == encrypted {
+		t.Error("encrypting twice gave the same value")
+	}
+
+	got, err := DecryptSecret(key, encrypted, ad)
+	if err != nil || got != plaintext {
+		t.Fatalf("DecryptSecret() = %q, %v, want %q", got, err, plaintext)
+	}
+
+	// A character in the middle always carries full bits of the ciphertext.
+	tampered := []byte(encrypted)
+	i := len(tampered) / 2
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+
+	tests := []struct {
+		name  string
+		key   []byte
+		value string
+		ad    string
+	}{
+		{"wrong key", DeriveKey("other-secret", "totp-secret"), encrypted, ad},
+		{"other purpose", DeriveKey("app-secret", "other"), encrypted, ad},
+		{"other row", key, encrypted, "totp_secret:2"},
+		{"tampered", key, string(tampered), ad},
+		{"plain text", key, plaintext, ad},
+		{"truncated", key, encryptedPrefix + "AAAA", ad},
+	}
+	for _, tc := range tests {
+		if _, err := DecryptSecret(tc.key, tc.value, tc.ad); err == nil {
+			t.Errorf("DecryptSecret() with %s succeeded", tc.name)
+		}
+	}
+}
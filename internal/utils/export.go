@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// RowWriter writes tabular data row by row so exports can be streamed
+// straight from the database cursor to the response.
+type RowWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []any) error
+	Close() error
+}
+
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/x-ndjson",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+var exportExtensions = map[string]string{
+	"csv":  "csv",
+	"json": "ndjson",
+	"xlsx": "xlsx",
+}
+
+func IsValidExportFormat(format string) bool {
+	_, ok := exportContentTypes[format]
+	return ok
+}
+
+func ExportContentType(format string) string {
+	return exportContentTypes[format]
+}
+
+func ExportFilename(name, format string) string {
+	return name + "." + exportExtensions[format]
+}
+
+func NewRowWriter(format string, w io.Writer) (RowWriter, error) {
+	switch format {
+	case "csv":
+		return &csvRowWriter{w: csv.NewWriter(w)}, nil
+	case "json":
+		return &jsonRowWriter{w: w}, nil
+	case "xlsx":
+		f := excelize.NewFile()
+		sw, err := f.NewStreamWriter("Sheet1")
+		if err != nil {
+			return nil, err
+		}
+		return &xlsxRowWriter{file: f, sw: sw, out: w}, nil
+	}
+	return nil, errors.New("unsupported export format")
+}
+
+func exportValue(v any) any {
+	switch val := v.(type) {
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case *time.Time:
+		if val == nil {
+			return nil
+		}
+		return val.Format(time.RFC3339)
+	case *int:
+		if val == nil {
+			return nil
+		}
+		return *val
+	case *string:
+		if val == nil {
+			return nil
+		}
+		return *val
+	}
+	return v
+}
+
+// escapeFormula prefixes a quote to text that a spreadsheet would otherwise
+// evaluate as a formula, such as a link title of "=HYPERLINK(...)".
+func escapeFormula(s string) string {
+	if s == "" {
+		return s
+	}
+	switch s[0] {
+	case '=', '+', '-', '@', '\t', '\r':
+		return "'" + s
+	}
+	return s
+}
+
+type csvRowWriter struct {
+	w    *csv.Writer
+	rows int
+}
+
+func (c *csvRowWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvRowWriter) WriteRow(values []any) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		switch val := exportValue(v).(type) {
+		case nil:
+			record[i] = ""
+		case string:
+			record[i] = escapeFormula(val)
+		default:
+			b, _ := json.Marshal(val)
+			record[i] = string(b)
+		}
+	}
+
+	if err := c.w.Write(record); err != nil {
+		return err
+	}
+
+	c.rows++
+	if c.rows%500 == 0 {
+		c.w.Flush()
+		return c.w.Error()
+	}
+	return nil
+}
+
+func (c *csvRowWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonRowWriter writes one object per row. It builds the objects itself, as
+// encoding/json would sort the keys of a map instead of keeping the column
+// order.
+type jsonRowWriter struct {
+	w       io.Writer
+	columns [][]byte
+	buf     bytes.Buffer
+}
+
+func (j *jsonRowWriter) WriteHeader(columns []string) error {
+	j.columns = make([][]byte, len(columns))
+	for i, col := range columns {
+		key, err := json.Marshal(col)
+		if err != nil {
+			return err
+		}
+		j.columns[i] = key
+	}
+	return nil
+}
+
+func (j *jsonRowWriter) WriteRow(values []any) error {
+	j.buf.Reset()
+	j.buf.WriteByte('{')
+	for i, v := range values {
+		if i >= len(j.columns) {
+			break
+		}
+		value, err := json.Marshal(exportValue(v))
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			j.buf.WriteByte(',')
+		}
+		j.buf.Write(j.columns[i])
+		j.buf.WriteByte(':')
+		j.buf.Write(value)
+	}
+	j.buf.WriteString("}\n")
+
+	_, err := j.w.Write(j.buf.Bytes())
+	return err
+}
+
+func (j *jsonRowWriter) Close() error {
+	return nil
+}
+
+// xlsxRowWriter relies on the excelize stream writer, which spills rows to a
+// temporary file instead of keeping the whole sheet in memory.
+type xlsxRowWriter struct {
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	out  io.Writer
+	row  int
+}
+
+func (x *xlsxRowWriter) WriteHeader(columns []string) error {
+	values := make([]any, len(columns))
+	for i, col := range columns {
+		values[i] = col
+	}
+	return x.WriteRow(values)
+}
+
+func (x *xlsxRowWriter) WriteRow(values []any) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+
+	row := make([]any, len(values))
+	for i, v := range values {
+		row[i] = exportValue(v)
+		if s, ok := row[i].(string); ok {
+			row[i] = escapeFormula(s)
+		}
+	}
+	return x.sw.SetRow(cell, row)
+}
+
+func (x *xlsxRowWriter) Close() error {
+	defer x.file.Close()
+
+	if err := x.sw.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.out)
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var formulaCells = []struct {
+	in, want string
+}{
+	{"=HYPERLINK(\"http://evil.test\")", "'=HYPERLINK(\"http://evil.test\")"},
+	{"+1+1", "'+1+1"},
+	{"-2+3", "'-2+3"},
+	{"@SUM(A1)", "'@SUM(A1)"},
+	{"\tcmd", "'\tcmd"},
+	{"\rcmd", "'\rcmd"},
+	{"https://example.com", "https://example.com"},
+	{"", ""},
+}
+
+func writeExport(t *testing.T, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w, err := NewRowWriter(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader([]string{"id", "title"}); err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range formulaCells {
+		if err := w.WriteRow([]any{i, tc.in}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVRowWriterEscapesFormulas(t *testing.T) {
+	records, err := csv.NewReader(bytes.NewReader(writeExport(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, tc := range formulaCells {
+		if got := records[i+1][1]; got != tc.want {
+			t.Errorf("cell %q = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestJSONRowWriterKeepsColumnOrder(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewRowWriter("json", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader([]string{"shortCode", "clicks", "createdAt", "expiresAt", "a<b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	var expiresAt *time.Time
+	rows := [][]any{
+		{"abc123", 42, createdAt, expiresAt, "x&y"},
+		{"def456", 0, &createdAt, nil, ""},
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"shortCode":"abc123","clicks":42,"createdAt":"2025-01-02T03:04:05Z","expiresAt":null,"a\u003cb":"x\u0026y"}` + "\n" +
+		`{"shortCode":"def456","clicks":0,"createdAt":"2025-01-02T03:04:05Z","expiresAt":null,"a\u003cb":""}` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("export =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestXLSXRowWriterEscapesFormulas(t *testing.T) {
+	f, err := excelize.OpenReader(bytes.NewReader(writeExport(t, "xlsx")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for i, tc := range formulaCells {
+		cell, _ := excelize.CoordinatesToCellName(2, i+2)
+		got, err := f.GetCellValue("Sheet1", cell)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("cell %q = %q, want %q", tc.in, got, tc.want)
+		}
+		formula, _ := f.GetCellFormula("Sheet1", cell)
+		if formula != "" {
+			t.Errorf("cell %q stored as formula %q", tc.in, formula)
+		}
+	}
+}
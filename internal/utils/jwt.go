@@ -1,7 +1,10 @@
 package utils
 
 import (
-	"os"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,13 +16,13 @@ type UserPayload struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateAccessToken(userId, sessionId int) (string, error) {
-	secretKey := []byte(os.Getenv("APP_SECRET"))
+func GenerateAccessToken(secret string, ttl time.Duration, userId, sessionId int) (string, error) {
+	secretKey := []byte(secret)
 	claims := UserPayload{
 		Id:        userId,
 		SessionId: sessionId,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -28,13 +31,15 @@ func GenerateAccessToken(userId, sessionId int) (string, error) {
 	return token.SignedString(secretKey)
 }
 
-func GenerateRefreshToken(userId int) (string, time.Time, error) {
-	secretKey := []byte(os.Getenv("REFRESH_SECRET"))
-	expiresAt := time.Now().Add(7 * 24 * time.Hour)
+func GenerateRefreshToken(secret string, ttl time.Duration, userId int) (string, time.Time, error) {
+	secretKey := []byte(secret)
+	expiresAt := time.Now().Add(ttl)
 
 	claims := UserPayload{
 		Id: userId,
 		RegisteredClaims: jwt.RegisteredClaims{
+			// A random ID keeps tokens issued within the same second unique.
+			ID:        rand.Text(),
 			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -45,8 +50,8 @@ func GenerateRefreshToken(userId int) (string, time.Time, error) {
 	return signedToken, expiresAt, err
 }
 
-func VerifyAccessToken(tokenString string) (*UserPayload, error) {
-	secretKey := []byte(os.Getenv("APP_SECRET"))
+func VerifyAccessToken(secret, tokenString string) (*UserPayload, error) {
+	secretKey := []byte(secret)
 
 	token, err := jwt.ParseWithClaims(tokenString, &UserPayload{}, func(token *jwt.Token) (any, error) {
 		return secretKey, nil
@@ -63,8 +68,8 @@ func VerifyAccessToken(tokenString string) (*UserPayload, error) {
 	return nil, jwt.ErrSignatureInvalid
 }
 
-func VerifyRefreshToken(tokenString string) (*UserPayload, error) {
-	secretKey := []byte(os.Getenv("REFRESH_SECRET"))
+func VerifyRefreshToken(secret, tokenString string) (*UserPayload, error) {
+	secretKey := []byte(secret)
 
 	token, err := jwt.ParseWithClaims(tokenString, &UserPayload{}, func(token *jwt.Token) (any, error) {
 		return secretKey, nil
@@ -80,3 +85,51 @@ func VerifyRefreshToken(tokenString string) (*UserPayload, error) {
 
 	return nil, jwt.ErrSignatureInvalid
 }
+
+// LinkUnlockPayload binds an unlock to one link and one password of it, so
+// the token neither carries over to a link recreated under the same code nor
+// survives a password change.
+type LinkUnlockPayload struct {
+	LinkID              int    `json:"linkId"`
+	PasswordFingerprint string `json:"pwd"`
+	jwt.RegisteredClaims
+}
+
+func GenerateLinkUnlockToken(secret string, linkID int, passwordHash string, ttl time.Duration) (string, error) {
+	secretKey := []byte(secret)
+	claims := LinkUnlockPayload{
+		LinkID:              linkID,
+		PasswordFingerprint: passwordFingerprint(secret, passwordHash),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{"link-unlock"},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(secretKey)
+}
+
+func VerifyLinkUnlockToken(secret, tokenString string, linkID int, passwordHash string) bool {
+	secretKey := []byte(secret)
+
+	token, err := jwt.ParseWithClaims(tokenString, &LinkUnlockPayload{}, func(token *jwt.Token) (any, error) {
+		return secretKey, nil
+	}, jwt.WithAudience("link-unlock"))
+	if err != nil {
+		return false
+	}
+
+	claims, ok := token.Claims.(*LinkUnlockPayload)
+	return ok && token.Valid && claims.LinkID == linkID &&
+		hmac.Equal([]byte(claims.PasswordFingerprint), []byte(passwordFingerprint(secret, passwordHash)))
+}
+
+// passwordFingerprint identifies a password hash without revealing it. The
+// token is readable by the visitor, so it is keyed with the app secret.
+func passwordFingerprint(secret, passwordHash string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(passwordHash))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
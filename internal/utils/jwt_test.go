@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLinkUnlockToken(t *testing.T) {
+	const secret = "test-app-secret"
+	const hash = "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$aGFzaA"
+
+	token, err := GenerateLinkUnlockToken(secret, 42, hash, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		token  string
+		linkID int
+		hash   string
+		want   bool
+	}{
+		{"same link and password", secret, token, 42, hash, true},
+		{"other link", secret, token, 43, hash, false},
+		{"changed password", secret, token, 42, hash + "x", false},
+		{"other secret", "other-secret", token, 42, hash, false},
+		{"tampered token", secret, token + "x", 42, hash, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := VerifyLinkUnlockToken(tc.secret, tc.token, tc.linkID, tc.hash); got != tc.want {
+				t.Errorf("VerifyLinkUnlockToken() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	expired, err := GenerateLinkUnlockToken(secret, 42, hash, -time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyLinkUnlockToken(secret, expired, 42, hash) {
+		t.Error("an expired token unlocked the link")
+	}
+
+	access, err := GenerateAccessToken(secret, time.Hour, 42, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if VerifyLinkUnlockToken(secret, access, 42, hash) {
+		t.Error("an access token unlocked the link")
+	}
+}
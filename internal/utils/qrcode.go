@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// QRCodeStyle describes how a QR code bitmap is rendered. Margin is the
+// quiet zone in modules and Logo, when set, is drawn on a background colored
+// box in the center, covering about a fifth of the width.
+type QRCodeStyle struct {
+	Size       int
+	Margin     int
+	Foreground color.RGBA
+	Background color.RGBA
+	Logo       image.Image
+}
+
+// qrLogoRatio is the share of the code width covered by the logo box, small
+// enough for error correction level Q or H to restore the hidden modules.
+const qrLogoRatio = 0.2
+
+// ParseHexColor parses "#rrggbb" or "rrggbb".
+func ParseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return color.RGBA{}, errors.New("invalid color")
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return color.RGBA{}, errors.New("invalid color")
+	}
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// qrScale returns the pixels per module and the offset that centers the code
+// in style.Size pixels.
+func qrScale(modules int, style QRCodeStyle) (int, int, error) {
+	total := modules + 2*style.Margin
+	scale := style.Size / total
+	if scale < 1 {
+		return 0, 0, errors.New("qr size too small")
+	}
+	return scale, (style.Size - scale*total) / 2, nil
+}
+
+// QRCodePNG renders bitmap, as returned by qrcode.QRCode.Bitmap without
+// border, as a PNG image of style.Size pixels.
+func QRCodePNG(bitmap [][]bool, style QRCodeStyle) ([]byte, error) {
+	scale, offset, err := qrScale(len(bitmap), style)
+	if err != nil {
+		return nil, err
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, style.Size, style.Size))
+	draw.Draw(img, img.Bounds(), image.NewUniform(style.Background), image.Point{}, draw.Src)
+
+	fg := image.NewUniform(style.Foreground)
+	origin := offset + style.Margin*scale
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				module := image.Rect(0, 0, scale, scale).Add(image.Pt(origin+x*scale, origin+y*scale))
+				draw.Draw(img, module, fg, image.Point{}, draw.Src)
+			}
+		}
+	}
+
+	if style.Logo != nil {
+		box, logoSize := qrLogoBox(len(bitmap)*scale, origin)
+		draw.Draw(img, box, image.NewUniform(style.Background), image.Point{}, draw.Src)
+
+		logo := ResizeSquare(style.Logo, logoSize)
+		at := image.Pt(box.Min.X+(box.Dx()-logoSize)/2, box.Min.Y+(box.Dy()-logoSize)/2)
+		draw.Draw(img, logo.Bounds().Add(at), logo, image.Point{}, draw.Over)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// qrLogoBox returns the centered box behind the logo of a code that is width
+// pixels wide and starts at origin, and the size of the logo inside it.
+func qrLogoBox(width, origin int) (image.Rectangle, int) {
+	side := int(float64(width) * qrLogoRatio)
+	padding := max(side/10, 1)
+	start := origin + (width-side)/2
+	return image.Rect(start, start, start+side, start+side), side - 2*padding
+}
+
+// QRCodeSVG renders bitmap as an SVG image of style.Size pixels. The modules
+// are drawn as one path, one subpath per run of dark modules.
+func QRCodeSVG(bitmap [][]bool, style QRCodeStyle) ([]byte, error) {
+	if _, _, err := qrScale(len(bitmap), style); err != nil {
+		return nil, err
+	}
+	total := len(bitmap) + 2*style.Margin
+
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+style.Margin, y+style.Margin, x-start, x-start)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		style.Size, style.Size, total, total)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, total, total, hexColor(style.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="%s"/>`, hexColor(style.Foreground), path.String())
+
+	if style.Logo != nil {
+		// The logo is embedded at the resolution it would have in a PNG of
+		// the same size.
+		pixelsPerModule := float64(style.Size) / float64(total)
+		_, logoPixels := qrLogoBox(int(float64(len(bitmap))*pixelsPerModule), 0)
+		logo, err := EncodeImage(ResizeSquare(style.Logo, max(logoPixels, 1)), "png")
+		if err != nil {
+			return nil, err
+		}
+
+		side := float64(len(bitmap)) * qrLogoRatio
+		start := float64(style.Margin) + (float64(len(bitmap))-side)/2
+		padding := side / 10
+		fmt.Fprintf(&buf, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>`,
+			start, start, side, side, hexColor(style.Background))
+		fmt.Fprintf(&buf, `<image x="%.2f" y="%.2f" width="%.2f" height="%.2f" href="data:image/png;base64,%s"/>`,
+			start+padding, start+padding, side-2*padding, side-2*padding, base64.StdEncoding.EncodeToString(logo))
+	}
+
+	buf.WriteString(`</svg>`)
+	return buf.Bytes(), nil
+}
+
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
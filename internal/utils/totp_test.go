@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of the RFC 6238 test vectors,
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 Appendix B, SHA-1, truncated to the last 6 of the 8 digits.
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range tests {
+		got, err := TOTPCode(rfc6238Secret, tc.unix/int64(TOTPPeriod.Seconds()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("TOTPCode at %d = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("TOTPCode accepted a secret that isn't base32")
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	// 287082 is the code of step 1, the seconds 30 to 59.
+	const code = "287082"
+
+	tests := []struct {
+		name     string
+		code     string
+		unix     int64
+		lastStep int64
+		ok       bool
+	}{
+		{"current step", code, 59, 0, true},
+		{"one step late", code, 89, 0, true},
+		{"one step early", code, 29, 0, true},
+		{"two steps late", code, 119, 0, false},
+		{"used step", code, 59, 1, false},
+		{"used later step", code, 59, 2, false},
+		{"spaced", "287 082", 59, 0, true},
+		{"too short", "28708", 59, 0, false},
+		{"too long", "2870820", 59, 0, false},
+		{"wrong", "287083", 59, 0, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := VerifyTOTP(rfc6238Secret, tc.code, time.Unix(tc.unix, 0), tc.lastStep)
+			if ok != tc.ok {
+				t.Fatalf("VerifyTOTP() = %v, want %v", ok, tc.ok)
+			}
+			if ok && step != 1 {
+				t.Errorf("VerifyTOTP() step = %d, want 1", step)
+			}
+		})
+	}
+}
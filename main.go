@@ -10,16 +10,26 @@ package main
 // @in header
 // @name Authorization
 
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-API-Key
+
 import (
 	"backend-koda-shortlink/internal/config"
 	"backend-koda-shortlink/internal/database"
 	"backend-koda-shortlink/internal/middlewares"
 	"backend-koda-shortlink/internal/routes"
 	"backend-koda-shortlink/pkg/response"
+	"context"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
+	_ "time/tzdata"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-migrate/migrate/v4"
@@ -37,15 +47,22 @@ import (
 func main() {
 	godotenv.Load()
 
-	runMigrations()
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	runMigrations(cfg.Database.URL)
 
-	database.InitDatabase()
-	config.InitRedis()
+	database.InitDatabase(cfg.Database.URL)
+	config.InitRedis(cfg.Redis.URL)
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
 	r.Use(gin.Recovery())
-	r.Use(middlewares.CorsMiddleware())
-	r.Use(middlewares.RateLimiter(60, time.Minute))
+	r.Use(middlewares.CorsMiddleware(cfg.Server.OriginURL))
 	r.Use(middlewares.RequestLogger())
 
 	r.GET("/", func(ctx *gin.Context) {
@@ -57,17 +74,44 @@ func main() {
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	routes.SetUpRoutes(r)
+	shutdownRoutes := routes.SetUpRoutes(r, cfg)
 
-	r.Run(":8080")
-}
+	srv := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-func runMigrations() {
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		log.Fatal("DATABASE_URL is not set")
+	go func() {
+		log.Printf("Server listening on %s", srv.Addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+	log.Printf("Received %s, shutting down", sig)
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+
+	// Stop accepting requests and let in-flight ones finish first, so their
+	// clicks are queued before the click queue is drained.
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server forced to shut down: %v", err)
 	}
 
+	shutdownRoutes(ctx)
+
+	config.CloseRedis()
+	database.CloseDatabase()
+
+	log.Println("Server stopped")
+}
+
+func runMigrations(databaseURL string) {
 	m, err := migrate.New(
 		"file://migrations",
 		databaseURL,
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_short_links_expired_at;
+
+ALTER TABLE "short_links"
+DROP COLUMN IF EXISTS "expired_at",
+DROP COLUMN IF EXISTS "max_clicks";
\ No newline at end of file
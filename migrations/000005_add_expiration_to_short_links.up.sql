@@ -0,0 +1,7 @@
+ALTER TABLE "short_links"
+ADD COLUMN "expired_at" timestamp,
+ADD COLUMN "max_clicks" int;
+
+CREATE INDEX idx_short_links_expired_at ON "short_links" ("expired_at")
+WHERE
+    "expired_at" IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS "session_refresh_tokens";
+
+DROP INDEX IF EXISTS idx_sessions_refresh_token_hash;
+
+-- Hashed tokens cannot be turned back into tokens, so end every session.
+UPDATE "sessions"
+SET "is_active" = false, "logout_time" = NOW()
+WHERE "is_active" = true;
+
+ALTER TABLE "sessions"
+RENAME COLUMN "refresh_token_hash" TO "refresh_token";
+
+CREATE INDEX idx_sessions_refresh_token ON "sessions" ("refresh_token");
\ No newline at end of file
@@ -0,0 +1,23 @@
+ALTER TABLE "sessions"
+RENAME COLUMN "refresh_token" TO "refresh_token_hash";
+
+-- Keep existing sessions valid by hashing the stored tokens in place.
+UPDATE "sessions"
+SET "refresh_token_hash" = encode(sha256(convert_to("refresh_token_hash", 'UTF8')), 'hex');
+
+DROP INDEX IF EXISTS idx_sessions_refresh_token;
+
+CREATE INDEX idx_sessions_refresh_token_hash ON "sessions" ("refresh_token_hash");
+
+-- Refresh tokens that were already rotated. Presenting one of them again
+-- means the token leaked, so the whole chain gets revoked.
+CREATE TABLE "session_refresh_tokens" (
+    "token_hash" text PRIMARY KEY,
+    "session_id" int NOT NULL,
+    "rotated_at" timestamp DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE "session_refresh_tokens"
+ADD FOREIGN KEY ("session_id") REFERENCES "sessions" ("id") ON DELETE CASCADE;
+
+CREATE INDEX idx_session_refresh_tokens_session_id ON "session_refresh_tokens" ("session_id");
\ No newline at end of file
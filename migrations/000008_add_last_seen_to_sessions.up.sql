@@ -0,0 +1,5 @@
+ALTER TABLE "sessions"
+ADD COLUMN "last_seen_at" timestamp DEFAULT (CURRENT_TIMESTAMP);
+
+UPDATE "sessions"
+SET "last_seen_at" = COALESCE("updated_at", "login_time");
\ No newline at end of file
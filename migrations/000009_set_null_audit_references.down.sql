@@ -0,0 +1,23 @@
+ALTER TABLE "users"
+DROP CONSTRAINT IF EXISTS "users_created_by_fkey",
+ADD CONSTRAINT "users_created_by_fkey" FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "users"
+DROP CONSTRAINT IF EXISTS "users_updated_by_fkey",
+ADD CONSTRAINT "users_updated_by_fkey" FOREIGN KEY ("updated_by") REFERENCES "users" ("id");
+
+ALTER TABLE "sessions"
+DROP CONSTRAINT IF EXISTS "sessions_created_by_fkey",
+ADD CONSTRAINT "sessions_created_by_fkey" FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "sessions"
+DROP CONSTRAINT IF EXISTS "sessions_updated_by_fkey",
+ADD CONSTRAINT "sessions_updated_by_fkey" FOREIGN KEY ("updated_by") REFERENCES "users" ("id");
+
+ALTER TABLE "short_links"
+DROP CONSTRAINT IF EXISTS "short_links_created_by_fkey",
+ADD CONSTRAINT "short_links_created_by_fkey" FOREIGN KEY ("created_by") REFERENCES "users" ("id");
+
+ALTER TABLE "short_links"
+DROP CONSTRAINT IF EXISTS "short_links_updated_by_fkey",
+ADD CONSTRAINT "short_links_updated_by_fkey" FOREIGN KEY ("updated_by") REFERENCES "users" ("id");
\ No newline at end of file
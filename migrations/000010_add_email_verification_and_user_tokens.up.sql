@@ -0,0 +1,21 @@
+ALTER TABLE "users"
+ADD COLUMN "email_verified_at" timestamp;
+
+-- Accounts created before verification existed stay usable.
+UPDATE "users"
+SET "email_verified_at" = COALESCE("created_at", CURRENT_TIMESTAMP);
+
+CREATE TABLE "user_tokens" (
+    "id" serial PRIMARY KEY,
+    "user_id" int NOT NULL,
+    "purpose" varchar(20) NOT NULL,
+    "token_hash" text UNIQUE NOT NULL,
+    "expired_at" timestamp NOT NULL,
+    "used_at" timestamp,
+    "created_at" timestamp DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE "user_tokens"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX idx_user_tokens_user_id_purpose ON "user_tokens" ("user_id", "purpose");
\ No newline at end of file
@@ -0,0 +1,17 @@
+CREATE TABLE "api_keys" (
+    "id" serial PRIMARY KEY,
+    "user_id" int NOT NULL,
+    "name" varchar(100) NOT NULL,
+    "prefix" varchar(16) NOT NULL,
+    "key_hash" text UNIQUE NOT NULL,
+    "scopes" text[] NOT NULL,
+    "expired_at" timestamp,
+    "last_used_at" timestamp,
+    "revoked_at" timestamp,
+    "created_at" timestamp DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE "api_keys"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX idx_api_keys_user_id ON "api_keys" ("user_id");
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS "user_identities" CASCADE;
\ No newline at end of file
@@ -0,0 +1,15 @@
+CREATE TABLE "user_identities" (
+    "id" serial PRIMARY KEY,
+    "user_id" int NOT NULL,
+    "provider" varchar(50) NOT NULL,
+    "subject" varchar(255) NOT NULL,
+    "email" varchar(255),
+    "last_login_at" timestamp,
+    "created_at" timestamp DEFAULT (CURRENT_TIMESTAMP),
+    UNIQUE ("provider", "subject")
+);
+
+ALTER TABLE "user_identities"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX idx_user_identities_user_id ON "user_identities" ("user_id");
\ No newline at end of file
@@ -0,0 +1,17 @@
+ALTER TABLE "users"
+ADD COLUMN "totp_secret" text,
+ADD COLUMN "totp_enabled_at" timestamp,
+ADD COLUMN "totp_last_step" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE "user_recovery_codes" (
+    "id" serial PRIMARY KEY,
+    "user_id" int NOT NULL,
+    "code_hash" text NOT NULL,
+    "used_at" timestamp,
+    "created_at" timestamp DEFAULT (CURRENT_TIMESTAMP)
+);
+
+ALTER TABLE "user_recovery_codes"
+ADD FOREIGN KEY ("user_id") REFERENCES "users" ("id") ON DELETE CASCADE;
+
+CREATE INDEX idx_user_recovery_codes_user_id ON "user_recovery_codes" ("user_id");
\ No newline at end of file
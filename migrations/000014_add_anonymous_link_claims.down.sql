@@ -0,0 +1,3 @@
+ALTER TABLE "short_links"
+DROP COLUMN IF EXISTS "expires_unless_claimed",
+DROP COLUMN IF EXISTS "claim_token_hash";
\ No newline at end of file
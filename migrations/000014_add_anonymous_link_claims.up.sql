@@ -0,0 +1,7 @@
+ALTER TABLE "short_links"
+ADD COLUMN "claim_token_hash" text,
+ADD COLUMN "expires_unless_claimed" bool NOT NULL DEFAULT false;
+
+CREATE UNIQUE INDEX idx_short_links_claim_token_hash ON "short_links" ("claim_token_hash")
+WHERE
+    "claim_token_hash" IS NOT NULL;
\ No newline at end of file
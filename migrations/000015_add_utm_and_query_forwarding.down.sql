@@ -0,0 +1,7 @@
+ALTER TABLE "short_links"
+DROP COLUMN IF EXISTS "forward_query",
+DROP COLUMN IF EXISTS "utm_content",
+DROP COLUMN IF EXISTS "utm_term",
+DROP COLUMN IF EXISTS "utm_campaign",
+DROP COLUMN IF EXISTS "utm_medium",
+DROP COLUMN IF EXISTS "utm_source";
\ No newline at end of file
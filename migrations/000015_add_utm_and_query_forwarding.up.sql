@@ -0,0 +1,7 @@
+ALTER TABLE "short_links"
+ADD COLUMN "utm_source" text NOT NULL DEFAULT '',
+ADD COLUMN "utm_medium" text NOT NULL DEFAULT '',
+ADD COLUMN "utm_campaign" text NOT NULL DEFAULT '',
+ADD COLUMN "utm_term" text NOT NULL DEFAULT '',
+ADD COLUMN "utm_content" text NOT NULL DEFAULT '',
+ADD COLUMN "forward_query" bool NOT NULL DEFAULT false;
\ No newline at end of file